@@ -2,6 +2,7 @@ package godog
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
@@ -22,20 +23,68 @@ type ExitFile struct {
 	Pid     int          `json:"pid"`
 	Time    string       `json:"time"`
 	Reasons []ReasonItem `json:"reasons"`
+	// Hooks 优雅退出时关闭钩子的执行结果
+	Hooks []HookResult `json:"hooks,omitempty"`
+	// Code godog 所在进程的退出码, 可以为 0, 没有退出(例如向其它进程发送信号)时为 nil
+	Code *int `json:"code,omitempty"`
+	// Signal 向被监控的进程发送信号的结果
+	Signal *SignalResult `json:"signal,omitempty"`
 }
 
 const DogExit = "Dog.exit"
 
+// targetPid 触发原因中被监控的进程号, 没有时为当前进程
+func targetPid(reasons []ReasonItem) int {
+	for _, r := range reasons {
+		if r.Pid > 0 {
+			return r.Pid
+		}
+	}
+	return os.Getpid()
+}
+
+// appendRecord 将动作的执行记录追加到 reasons 中, 不修改原 reasons
+func appendRecord(reasons []ReasonItem, item ReasonItem) []ReasonItem {
+	if item.Severity == "" && len(reasons) > 0 {
+		item.Severity = reasons[0].Severity
+	}
+	return append(reasons[:len(reasons):len(reasons)], item)
+}
+
+// WriteExitFile 在 dir 目录下生成 Dog.exit 文件
+func WriteExitFile(dir string, f ExitFile) error {
+	data, err := json.Marshal(f)
+	if err != nil {
+		return fmt.Errorf("marshal exit file: %w", err)
+	}
+
+	name := filepath.Join(dir, DogExit)
+	if err := os.WriteFile(name, data, os.ModePerm); err != nil {
+		return fmt.Errorf("write exit file %s: %w", name, err)
+	}
+	return nil
+}
+
+// DefaultWarnAction 警告级别的默认动作, 只打印日志
+var DefaultWarnAction = func(dir string, debug bool, reasons []ReasonItem) {
+	log.Printf("W! godog warning, reason: %v", reasons)
+}
+
+// DefaultAction 默认动作, 生成 Dog.exit 后退出, 被监控的是其它进程时改为按 DefaultSignalSteps 向其发送信号
 var DefaultAction = func(dir string, debug bool, reasons []ReasonItem) {
+	if targetPid(reasons) != os.Getpid() {
+		(&SignalAction{}).DoAction(dir, debug, reasons)
+		return
+	}
+
 	log.Printf("program exit by godog, reason: %v", reasons)
 
-	data, _ := json.Marshal(ExitFile{
+	code := 1
+	_ = WriteExitFile(dir, ExitFile{
 		Pid:     os.Getpid(),
 		Time:    time.Now().Format(time.RFC3339),
 		Reasons: reasons,
+		Code:    &code,
 	})
-
-	name := filepath.Join(dir, DogExit)
-	_ = os.WriteFile(name, data, os.ModePerm)
-	os.Exit(1)
+	os.Exit(code)
 }
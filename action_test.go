@@ -0,0 +1,40 @@
+package godog
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteExitFileCode(t *testing.T) {
+	zero := 0
+	tests := []struct {
+		name string
+		code *int
+		want any
+	}{
+		{"exit code 0", &zero, float64(0)},
+		{"not exited", nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := WriteExitFile(dir, ExitFile{Pid: 1, Code: tt.code}); err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(filepath.Join(dir, DogExit))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var m map[string]any
+			if err := json.Unmarshal(data, &m); err != nil {
+				t.Fatal(err)
+			}
+			if got, ok := m["code"]; got != tt.want || ok != (tt.want != nil) {
+				t.Fatalf("code = %v (present %t), want %v in %s", got, ok, tt.want, data)
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package godog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const (
+	// AdminPrefix 管理接口的路径前缀
+	AdminPrefix = "/debug/godog/"
+	// DefaultAdminCPUProfile 管理接口采集 CPU 性能分析的默认时长
+	DefaultAdminCPUProfile = 30 * time.Second
+	// AdminProfileSource 管理接口生成的性能分析文件在指标中的类型
+	AdminProfileSource = "admin"
+)
+
+// AdminStatus 管理接口返回的当前设置和状态
+type AdminStatus struct {
+	Pid      int    `json:"pid"`
+	Running  bool   `json:"running"`
+	Paused   bool   `json:"paused"`
+	Interval string `json:"interval"`
+	Jitter   string `json:"jitter"`
+	Times    int    `json:"times"`
+	Dir      string `json:"dir"`
+	State    State  `json:"state"`
+}
+
+// AdminConfig 管理接口修改设置的请求, 空值表示不修改,
+// 阈值的格式与环境变量相同, 例如 {"thresholds": {"RSS": "512MiB"}, "warnThresholds": {"RSS": "384MiB"}}
+type AdminConfig struct {
+	Interval       string                   `json:"interval,omitempty"`
+	Jitter         string                   `json:"jitter,omitempty"`
+	Times          int                      `json:"times,omitempty"`
+	Thresholds     map[ThresholdType]string `json:"thresholds,omitempty"`
+	WarnThresholds map[ThresholdType]string `json:"warnThresholds,omitempty"`
+}
+
+// AdminHandler 管理接口, 挂载在 AdminPrefix 下:
+//
+//	GET  /debug/godog/                                  当前设置和状态
+//	POST /debug/godog/profile?type=heap|cpu|goroutine   采集性能分析, cpu 可以用 seconds 指定时长
+//	POST /debug/godog/config                            修改间隔, 次数和阈值, 请求体为 AdminConfig
+//	POST /debug/godog/pause                             暂停监控
+//	POST /debug/godog/resume                            恢复监控
+//
+// 管理接口可以修改阈值和暂停监控, 应只监听在本机或内网地址
+func AdminHandler(dog *Dog) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+AdminPrefix+"{$}", func(rw http.ResponseWriter, _ *http.Request) {
+		writeJSON(rw, dog.adminStatus())
+	})
+	mux.HandleFunc("POST "+AdminPrefix+"profile", dog.adminProfile)
+	mux.HandleFunc("POST "+AdminPrefix+"config", dog.adminConfig)
+	mux.HandleFunc("POST "+AdminPrefix+"pause", func(rw http.ResponseWriter, _ *http.Request) {
+		dog.Pause()
+		writeJSON(rw, dog.adminStatus())
+	})
+	mux.HandleFunc("POST "+AdminPrefix+"resume", func(rw http.ResponseWriter, _ *http.Request) {
+		dog.Resume()
+		writeJSON(rw, dog.adminStatus())
+	})
+	return mux
+}
+
+func (w *Dog) adminStatus() AdminStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return AdminStatus{
+		Pid:      w.Pid,
+		Running:  w.Running(),
+		Paused:   w.Paused(),
+		Interval: w.Interval.String(),
+		Jitter:   w.Jitter.String(),
+		Times:    w.Times,
+		Dir:      w.Dir,
+		State:    w.Snapshot(),
+	}
+}
+
+func (w *Dog) adminProfile(rw http.ResponseWriter, r *http.Request) {
+	// 性能分析只能采集看门狗所在的进程
+	c := w.config()
+	if c.Pid != os.Getpid() {
+		http.Error(rw, fmt.Sprintf("profiles are only available for pid %d", os.Getpid()), http.StatusBadRequest)
+		return
+	}
+
+	var (
+		p   Profile
+		err error
+	)
+	switch typ := r.FormValue("type"); typ {
+	case "heap":
+		p, err = CreateMemProfile(c.Dir, c.Pid)
+	case "goroutine":
+		p, err = CreateGoroutineProfile(c.Dir, c.Pid)
+	case "cpu":
+		duration := DefaultAdminCPUProfile
+		if s := r.FormValue("seconds"); s != "" {
+			seconds, err := strconv.Atoi(s)
+			if err != nil || seconds <= 0 {
+				http.Error(rw, fmt.Sprintf("invalid seconds %q", s), http.StatusBadRequest)
+				return
+			}
+			duration = time.Duration(seconds) * time.Second
+		}
+		// 使用单独的文件名, 避免与超标时的 CPU 性能分析文件互相覆盖
+		name := filepath.Join(c.Dir, fmt.Sprintf("Dog.cpu.admin.%d.prof", c.Pid))
+		if p, err = createCPUProfile(name); err != nil {
+			// 可能是超标时的 CPU 性能分析正在进行
+			http.Error(rw, err.Error(), http.StatusConflict)
+			return
+		}
+		select {
+		case <-time.After(duration):
+		case <-r.Context().Done():
+		}
+		err = p.Close()
+	default:
+		http.Error(rw, fmt.Sprintf("unknown profile type %q, use heap, cpu or goroutine", typ), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.counters.addProfile(AdminProfileSource)
+	writeJSON(rw, map[string]string{"profile": p.ProfileName()})
+}
+
+func (w *Dog) adminConfig(rw http.ResponseWriter, r *http.Request) {
+	var c AdminConfig
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		http.Error(rw, fmt.Sprintf("decode config: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := w.applyAdminConfig(c); err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(rw, w.adminStatus())
+}
+
+func (w *Dog) applyAdminConfig(c AdminConfig) error {
+	interval, jitter := w.intervals()
+	var err error
+	if c.Interval != "" {
+		if interval, err = time.ParseDuration(c.Interval); err != nil {
+			return fmt.Errorf("parse interval: %w", err)
+		}
+	}
+	if c.Jitter != "" {
+		if jitter, err = time.ParseDuration(c.Jitter); err != nil {
+			return fmt.Errorf("parse jitter: %w", err)
+		}
+	} else {
+		jitter = min(jitter, interval) // 只修改间隔时, 原有的抖动不超过新的间隔
+	}
+
+	criticals, err := parseThresholds(c.Thresholds)
+	if err != nil {
+		return err
+	}
+	warns, err := parseThresholds(c.WarnThresholds)
+	if err != nil {
+		return err
+	}
+
+	// 先检查阈值是否都在监控中, 再修改设置
+	w.mu.Lock()
+	_, errCritical := w.findStates(Critical, criticals)
+	_, errWarn := w.findStates(Warn, warns)
+	w.mu.Unlock()
+	if err := errors.Join(errCritical, errWarn); err != nil {
+		return err
+	}
+	if c.Times < 0 {
+		return fmt.Errorf("invalid times %d", c.Times)
+	}
+	if c.Interval != "" || c.Jitter != "" {
+		if err := w.SetInterval(interval, jitter); err != nil {
+			return err
+		}
+	}
+
+	if err := w.SetThresholds(Critical, criticals); err != nil {
+		return err
+	}
+	if err := w.SetThresholds(Warn, warns); err != nil {
+		return err
+	}
+	if c.Times > 0 {
+		return w.SetTimes(c.Times)
+	}
+	return nil
+}
+
+func parseThresholds(m map[ThresholdType]string) (map[ThresholdType]uint64, error) {
+	thresholds := make(map[ThresholdType]uint64, len(m))
+	var errs []error
+	for typ, s := range m {
+		v, err := ParseThreshold(typ, s)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		thresholds[typ] = v
+	}
+	return thresholds, errors.Join(errs...)
+}
+
+func writeJSON(rw http.ResponseWriter, v any) {
+	rw.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_ = json.NewEncoder(rw).Encode(v)
+}
@@ -6,30 +6,52 @@ import (
 	"os"
 
 	"github.com/bingoohuang/godog"
-	"github.com/bingoohuang/godog/busy"
+	"github.com/bingoohuang/godog/autoload/loader"
 	_ "github.com/joho/godotenv/autoload"
 )
 
-func init() {
-	c := &godog.Config{
-		Pid:                 os.Getpid(),
-		Dir:                 os.Getenv("DOG_DIR"),
-		Debug:               os.Getenv("DOG_DEBUG") == "1",
-		RSSThreshold:        godog.GetEnvSize("DOG_RSS", godog.DefaultRSSThreshold),
-		CPUPercentThreshold: godog.GetEnvInt("DOG_CPU", uint64(godog.DefaultCPUThreshold)),
-		Interval:            godog.GetEnvDuration("DOG_INTERVAL", godog.DefaultInterval),
-		Jitter:              godog.GetEnvDuration("DOG_JITTER", godog.DefaultJitter),
-		Times:               int(godog.GetEnvInt("DOG_TIMES", godog.DefaultTimes)),
+// watchdog 自动加载的看门狗, 配置有误且 DOG_ON_ERROR=disable 时为 nil
+var watchdog *loader.Watchdog
+
+// dog 自动加载的看门狗, 未启动时仍然可用于注册关闭钩子
+var dog *godog.Dog
+
+// Dog 返回自动加载的看门狗, 可用于查看状态或停止监控
+func Dog() *godog.Dog { return dog }
+
+// Stop 停止自动加载的看门狗, Dog.busy 监控, 配置文件监控, 指标和管理服务, 关闭未完成的性能分析文件
+func Stop(ctx context.Context) error {
+	if watchdog == nil {
+		return dog.Stop(ctx)
 	}
+	return watchdog.Stop(ctx)
+}
+
+// OnShutdown 注册优雅退出(设置了 DOG_SHUTDOWN_TIMEOUT)时执行的关闭钩子
+func OnShutdown(hook godog.ShutdownHook) { dog.OnShutdown(hook) }
 
-	ctx := context.Background()
-	dog := godog.New(godog.WithConfig(c))
-	go func() {
-		if err := dog.Watch(ctx); err != nil && c.Debug {
-			log.Printf("watch error: %v", err)
-		}
-	}()
+// Context 返回看门狗的上下文, 在优雅退出开始时取消
+func Context() context.Context { return dog.Context() }
 
-	bi := godog.GetEnvDuration("DOG_BUSY_INTERVAL", busy.DefaultCheckBusyInterval)
-	go busy.Watch(ctx, c.Dir, c.Debug, bi)
+// 配置有误时的处理方式, 由 DOG_ON_ERROR 指定
+const (
+	// OnErrorFallback 记录日志, 有误的取值使用默认值
+	OnErrorFallback = loader.OnErrorFallback
+	// OnErrorDisable 记录日志, 不启动看门狗
+	OnErrorDisable = loader.OnErrorDisable
+	// OnErrorFatal 记录日志后退出程序
+	OnErrorFatal = loader.OnErrorFatal
+)
+
+func init() {
+	w, err := loader.New(loader.Options{})
+	if err != nil {
+		dog = godog.New(godog.WithPid(os.Getpid()))
+		return
+	}
+
+	watchdog, dog = w, w.Dog
+	if err := w.Start(); err != nil && dog.Debug {
+		log.Printf("watch error: %v", err)
+	}
 }
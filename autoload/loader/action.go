@@ -0,0 +1,159 @@
+package loader
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/bingoohuang/godog"
+)
+
+// fileConfig DOG_CONFIG 配置文件的内容, 未设置时为空, 只在 init 和重新加载配置时修改
+var fileConfig = &godog.FileConfig{}
+
+func init() {
+	godog.RegisterAction("webhook", func(*godog.Dog) (godog.Action, error) { return webhookAction(nil) })
+	godog.RegisterAction("exec", func(*godog.Dog) (godog.Action, error) { return execAction(nil) })
+	godog.RegisterAction("signal", func(*godog.Dog) (godog.Action, error) { return signalAction() })
+}
+
+// buildActions 创建动作, Options 或配置文件的 actions 和 warnActions (或 DOG_ACTIONS 和 DOG_WARN_ACTIONS) 按名称组装动作链,
+// 否则根据配置文件的 exec 和 webhook (或 DOG_ACTION_EXEC 和 DOG_WEBHOOK_URL) 在默认动作之前执行命令和通知,
+// warn 为 nil 时使用默认的警告动作
+func buildActions(dog *godog.Dog, c *godog.Config, o Options) (action, warn godog.Action, err error) {
+	if names := cmp.Or(o.WarnActions, fileConfig.WarnActions, os.Getenv("DOG_WARN_ACTIONS")); names != "" {
+		if warn, err = godog.ParseActions(dog, names); err != nil {
+			return nil, nil, fmt.Errorf("parse warn actions: %w", err)
+		}
+	}
+
+	if names := cmp.Or(o.Actions, fileConfig.Actions, os.Getenv("DOG_ACTIONS")); names != "" {
+		if action, err = godog.ParseActions(dog, names); err != nil {
+			return nil, nil, fmt.Errorf("parse actions: %w", err)
+		}
+		return action, warn, nil
+	}
+
+	if c.ShutdownTimeout > 0 {
+		action = dog.GracefulExitAction()
+	} else {
+		action = godog.ActionFn(godog.DefaultAction)
+	}
+	if fileConfig.Exec != nil || os.Getenv("DOG_ACTION_EXEC") != "" {
+		// 默认执行命令后仍然退出
+		if action, err = execAction(action); err != nil {
+			return nil, nil, err
+		}
+	}
+	if fileConfig.Webhook != nil || os.Getenv("DOG_WEBHOOK_URL") != "" {
+		if action, err = webhookAction(action); err != nil {
+			return nil, nil, err
+		}
+	}
+	return action, warn, nil
+}
+
+// execAction 根据配置文件的 exec 或 DOG_ACTION_EXEC 创建执行命令的动作
+func execAction(next godog.Action) (godog.Action, error) {
+	if fileConfig.Exec != nil {
+		a, err := fileConfig.Exec.Action(next)
+		if err != nil {
+			return nil, err
+		}
+		return a, nil
+	}
+
+	if os.Getenv("DOG_ACTION_EXEC") == "" {
+		return nil, errors.New("env DOG_ACTION_EXEC is empty")
+	}
+	if os.Getenv("DOG_ACTION_EXEC_EXIT") == "0" {
+		next = nil
+	}
+	return execFromEnv(next)
+}
+
+// webhookAction 根据配置文件的 webhook 或 DOG_WEBHOOK_URL 创建通知动作
+func webhookAction(next godog.Action) (godog.Action, error) {
+	if fileConfig.Webhook != nil {
+		a, err := fileConfig.Webhook.Action(next)
+		if err != nil {
+			return nil, err
+		}
+		return a, nil
+	}
+
+	if os.Getenv("DOG_WEBHOOK_URL") == "" {
+		return nil, errors.New("env DOG_WEBHOOK_URL is empty")
+	}
+	return webhookFromEnv(next)
+}
+
+// signalAction 根据配置文件的 signal 或 DOG_SIGNALS, DOG_SIGNAL_GROUP 和 DOG_SIGNAL_CHILD 创建发送信号的动作
+func signalAction() (godog.Action, error) {
+	if fileConfig.Signal != nil {
+		a, err := fileConfig.Signal.Action()
+		if err != nil {
+			return nil, err
+		}
+		return a, nil
+	}
+
+	a := &godog.SignalAction{
+		Group: os.Getenv("DOG_SIGNAL_GROUP") == "1",
+		Child: os.Getenv("DOG_SIGNAL_CHILD") == "1",
+	}
+	if env := os.Getenv("DOG_SIGNALS"); env != "" {
+		steps, err := godog.ParseSignalSteps(env)
+		if err != nil {
+			return nil, fmt.Errorf("parse env DOG_SIGNALS=%q error: %w", env, err)
+		}
+		a.Steps = steps
+	}
+	return a, nil
+}
+
+func execFromEnv(next godog.Action) (godog.Action, error) {
+	var r godog.EnvReader
+	a := &godog.ExecAction{
+		Command: os.Getenv("DOG_ACTION_EXEC"),
+		Timeout: r.Duration("DOG_ACTION_EXEC_TIMEOUT", godog.DefaultExecTimeout),
+		Next:    next,
+	}
+	if err := r.Err(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func webhookFromEnv(next godog.Action) (godog.Action, error) {
+	var r godog.EnvReader
+	template, err := getEnvFileOrValue("DOG_WEBHOOK_TEMPLATE")
+	a := &godog.WebhookAction{
+		URL:      os.Getenv("DOG_WEBHOOK_URL"),
+		Template: template,
+		Headers:  godog.ParseHeaders(os.Getenv("DOG_WEBHOOK_HEADERS")),
+		Timeout:  r.Duration("DOG_WEBHOOK_TIMEOUT", godog.DefaultWebhookTimeout),
+		Retries:  int(r.Int("DOG_WEBHOOK_RETRIES", 3)),
+		Next:     next,
+	}
+	if err := errors.Join(err, r.Err()); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// getEnvFileOrValue 读取环境变量, 以 @ 开头时读取对应文件的内容
+func getEnvFileOrValue(name string) (string, error) {
+	env := os.Getenv(name)
+	if !strings.HasPrefix(env, "@") {
+		return env, nil
+	}
+
+	data, err := os.ReadFile(env[1:])
+	if err != nil {
+		return "", fmt.Errorf("read env %s file error: %w", name, err)
+	}
+	return string(data), nil
+}
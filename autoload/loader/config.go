@@ -0,0 +1,105 @@
+package loader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/bingoohuang/godog"
+)
+
+// DefaultConfigInterval 检查 DOG_CONFIG 配置文件变化的默认间隔
+const DefaultConfigInterval = 10 * time.Second
+
+// loadConfig 读取环境变量和配置文件, 环境变量的错误记录在 r 中, 配置文件有误时不使用配置文件,
+// 取值无效(见 godog.Config.Sanitize)的字段恢复为默认值, 出错时返回的配置仍然可以使用
+func loadConfig(r *godog.EnvReader, path string) (*godog.Config, error) {
+	c := configFromEnv(r)
+	var errs []error
+	if path != "" {
+		// 配置文件有误时部分取值已经覆盖, 在副本上读取, Apply 不会修改共享的 map
+		fc := *c
+		if err := loadConfigFile(path, &fc); err != nil {
+			errs = append(errs, err)
+		} else {
+			c = &fc
+		}
+	}
+	if err := c.Sanitize(); err != nil {
+		errs = append(errs, err)
+	}
+	return c, errors.Join(errs...)
+}
+
+// loadConfigFile 读取配置文件并覆盖到 c 上, 成功后替换 fileConfig
+func loadConfigFile(path string, c *godog.Config) error {
+	f, err := godog.LoadConfigFile(path)
+	if err != nil {
+		return err
+	}
+	if err := f.Apply(c); err != nil {
+		return fmt.Errorf("apply config %s: %w", path, err)
+	}
+
+	fileConfig = f
+	return nil
+}
+
+// reloadConfig 重新读取环境变量和配置文件, 创建动作后重新加载看门狗, 出错时保留原有的配置,
+// 环境变量不会变化, 其中的错误在启动时已经处理过
+func (w *Watchdog) reloadConfig() error {
+	c := configFromEnv(&godog.EnvReader{})
+	old := fileConfig
+	if err := loadConfigFile(w.path, c); err != nil {
+		return err
+	}
+
+	action, warn, err := buildActions(w.Dog, c, w.options)
+	if err == nil {
+		c.Action, c.WarnAction = action, warn
+		err = w.Dog.Reload(w.configFns(c)...)
+	}
+	if err != nil {
+		fileConfig = old
+		return err
+	}
+	return nil
+}
+
+// watchConfig 定时检查配置文件的修改时间和大小, 变化时重新加载, 加载失败时记录日志并保留原有的配置
+func (w *Watchdog) watchConfig(ctx context.Context, interval time.Duration) {
+	path := w.path
+	last, _ := os.Stat(path)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			if last != nil {
+				log.Printf("E! stat DOG_CONFIG %s error: %v", path, err)
+			}
+			last = nil
+			continue
+		}
+		if last != nil && info.ModTime().Equal(last.ModTime()) && info.Size() == last.Size() {
+			continue
+		}
+
+		last = info
+		if err := w.reloadConfig(); err != nil {
+			log.Printf("E! reload DOG_CONFIG %s rejected: %v", path, err)
+		} else {
+			log.Printf("godog reloaded DOG_CONFIG %s", path)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package loader
+
+import (
+	"log"
+	"os"
+	"strings"
+)
+
+// knownEnvs autoload 读取的环境变量, 阈值对应的 _POLICY, _CLEAR 和 _WARN 见 thresholdEnvs
+var knownEnvs = map[string]bool{
+	"DOG_DEBUG": true, "DOG_DIR": true, "DOG_CONFIG": true, "DOG_CONFIG_INTERVAL": true, "DOG_ON_ERROR": true,
+	"DOG_CGROUP_ROOT": true, "DOG_TREND_HORIZON": true, "DOG_TREND_WINDOW": true,
+	"DOG_INTERVAL": true, "DOG_JITTER": true, "DOG_TIMES": true,
+	"DOG_COOLDOWN": true, "DOG_MAX_COOLDOWN": true, "DOG_MAX_FIRES": true, "DOG_FIRE_WINDOW": true,
+	"DOG_SHUTDOWN_TIMEOUT": true, "DOG_EXIT_CODE": true, "DOG_BUSY_INTERVAL": true,
+	"DOG_METRICS_ADDR": true, "DOG_ADMIN_ADDR": true,
+	"DOG_ACTIONS": true, "DOG_WARN_ACTIONS": true,
+	"DOG_ACTION_EXEC": true, "DOG_ACTION_EXEC_EXIT": true, "DOG_ACTION_EXEC_TIMEOUT": true,
+	"DOG_WEBHOOK_URL": true, "DOG_WEBHOOK_TEMPLATE": true, "DOG_WEBHOOK_HEADERS": true,
+	"DOG_WEBHOOK_TIMEOUT": true, "DOG_WEBHOOK_RETRIES": true,
+	"DOG_SIGNALS": true, "DOG_SIGNAL_GROUP": true, "DOG_SIGNAL_CHILD": true, "DOG_PROCESS_TREE": true,
+}
+
+// warnUnknownEnvs 对不认识的 DOG_ 开头的环境变量(通常是拼写错误)打印警告
+func warnUnknownEnvs() {
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		if strings.HasPrefix(name, "DOG_") && !isKnownEnv(name) {
+			log.Printf("W! unknown env %s is ignored by godog", name)
+		}
+	}
+}
+
+func isKnownEnv(name string) bool {
+	if knownEnvs[name] {
+		return true
+	}
+	for _, e := range thresholdEnvs {
+		switch name {
+		case e.name, e.name + "_POLICY", e.name + "_CLEAR", e.name + "_WARN":
+			return true
+		}
+	}
+	return false
+}
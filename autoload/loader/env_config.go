@@ -0,0 +1,111 @@
+package loader
+
+import (
+	"os"
+	"time"
+
+	"github.com/bingoohuang/godog"
+)
+
+// configFromEnv 从环境变量读取配置, 取值有误时使用默认值, 错误记录在 r 中
+func configFromEnv(r *godog.EnvReader) *godog.Config {
+	rss, rssPercent := r.SizeOrPercent("DOG_RSS", godog.DefaultRSSThreshold)
+	cpu, cpuPercent := r.IntOrPercent("DOG_CPU", godog.DefaultCPUThreshold)
+	cgMem, cgMemPercent := r.SizeOrPercent("DOG_CGROUP_MEM", 0)
+	return &godog.Config{
+
+		Dir:                          os.Getenv("DOG_DIR"),
+		Debug:                        os.Getenv("DOG_DEBUG") == "1",
+		RSSThreshold:                 rss,
+		RSSThresholdPercent:          rssPercent,
+		CPUPercentThreshold:          cpu,
+		CPUThresholdPercent:          cpuPercent,
+		CgroupRoot:                   os.Getenv("DOG_CGROUP_ROOT"),
+		ProcessTree:                  os.Getenv("DOG_PROCESS_TREE") == "1",
+		CgroupMemoryThreshold:        cgMem,
+		CgroupMemoryThresholdPercent: cgMemPercent,
+		ThrottledThreshold:           r.Int("DOG_THROTTLED", 0),
+		ThrottledTimeThreshold:       r.Duration("DOG_THROTTLED_TIME", 0),
+		FDThreshold:                  r.Int("DOG_FDS", 0),
+		ThreadsThreshold:             r.Int("DOG_THREADS", 0),
+		GoroutineThreshold:           r.Int("DOG_GOROUTINES", 0),
+		HeapLiveThreshold:            r.Size("DOG_HEAP_LIVE", 0),
+		HeapObjectsThreshold:         r.Int("DOG_HEAP_OBJECTS", 0),
+		StacksThreshold:              r.Size("DOG_STACKS", 0),
+		HeapFreeThreshold:            r.Size("DOG_HEAP_FREE", 0),
+		GCCPUThreshold:               r.Int("DOG_GC_CPU", 0),
+		GCPauseThreshold:             r.Duration("DOG_GC_PAUSE", 0),
+		TrendHorizon:                 r.Duration("DOG_TREND_HORIZON", 0),
+		TrendWindow:                  int(r.Int("DOG_TREND_WINDOW", godog.DefaultTrendWindow)),
+		ShutdownTimeout:              r.Duration("DOG_SHUTDOWN_TIMEOUT", 0),
+		ExitCode:                     exitCodeFromEnv(r),
+		Cooldown:                     r.Duration("DOG_COOLDOWN", 0),
+		MaxCooldown:                  r.Duration("DOG_MAX_COOLDOWN", godog.DefaultMaxCooldown),
+		MaxFires:                     int(r.Int("DOG_MAX_FIRES", 0)),
+		FireWindow:                   r.Duration("DOG_FIRE_WINDOW", godog.DefaultFireWindow),
+		Interval:                     r.Duration("DOG_INTERVAL", godog.DefaultInterval),
+		Jitter:                       r.Duration("DOG_JITTER", godog.DefaultJitter),
+		Times:                        int(r.Int("DOG_TIMES", godog.DefaultTimes)),
+		Policies:                     policiesFromEnv(r),
+		WarnThresholds:               warnThresholdsFromEnv(r),
+	}
+}
+
+// thresholdEnvs 阈值类型对应的环境变量名称, 以及对应的解析函数
+var thresholdEnvs = []struct {
+	typ   godog.ThresholdType
+	name  string
+	parse func(r *godog.EnvReader, name string, defaultValue uint64) uint64
+}{
+	{godog.RSS, "DOG_RSS", (*godog.EnvReader).Size},
+	{godog.CPU, "DOG_CPU", (*godog.EnvReader).Int},
+	{godog.CgroupMemory, "DOG_CGROUP_MEM", (*godog.EnvReader).Size},
+	{godog.Throttled, "DOG_THROTTLED", (*godog.EnvReader).Int},
+	{godog.ThrottledTime, "DOG_THROTTLED_TIME", getEnvDuration},
+	{godog.FD, "DOG_FDS", (*godog.EnvReader).Int},
+	{godog.Threads, "DOG_THREADS", (*godog.EnvReader).Int},
+	{godog.Goroutines, "DOG_GOROUTINES", (*godog.EnvReader).Int},
+	{godog.HeapLive, "DOG_HEAP_LIVE", (*godog.EnvReader).Size},
+	{godog.HeapObjects, "DOG_HEAP_OBJECTS", (*godog.EnvReader).Int},
+	{godog.Stacks, "DOG_STACKS", (*godog.EnvReader).Size},
+	{godog.HeapFree, "DOG_HEAP_FREE", (*godog.EnvReader).Size},
+	{godog.GCCPU, "DOG_GC_CPU", (*godog.EnvReader).Int},
+	{godog.GCPause, "DOG_GC_PAUSE", getEnvDuration},
+}
+
+func getEnvDuration(r *godog.EnvReader, name string, defaultValue uint64) uint64 {
+	return uint64(r.Duration(name, time.Duration(defaultValue)))
+}
+
+// policiesFromEnv 读取判定策略, 例如 DOG_RSS_POLICY=3/5 表示最近 5 次采样中 3 次超标, DOG_RSS_CLEAR=200MiB 表示超标后回落到 200MiB 才恢复
+func policiesFromEnv(r *godog.EnvReader) map[godog.ThresholdType]godog.Policy {
+	policies := map[godog.ThresholdType]godog.Policy{}
+	for _, e := range thresholdEnvs {
+		times, window := r.Policy(e.name + "_POLICY")
+		clear := e.parse(r, e.name+"_CLEAR", 0)
+		if times > 0 || clear > 0 {
+			policies[e.typ] = godog.Policy{Times: times, Window: window, Clear: clear}
+		}
+	}
+	return policies
+}
+
+// warnThresholdsFromEnv 读取警告级别上限, 例如 DOG_RSS_WARN=200MiB
+func warnThresholdsFromEnv(r *godog.EnvReader) map[godog.ThresholdType]uint64 {
+	warns := map[godog.ThresholdType]uint64{}
+	for _, e := range thresholdEnvs {
+		if warn := e.parse(r, e.name+"_WARN", 0); warn > 0 {
+			warns[e.typ] = warn
+		}
+	}
+	return warns
+}
+
+// exitCodeFromEnv 读取 DOG_EXIT_CODE, 未设置时返回 nil 使用默认的退出码, 可以设置为 0
+func exitCodeFromEnv(r *godog.EnvReader) *int {
+	if os.Getenv("DOG_EXIT_CODE") == "" {
+		return nil
+	}
+	code := int(r.Int("DOG_EXIT_CODE", godog.DefaultExitCode))
+	return &code
+}
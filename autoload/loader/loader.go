@@ -0,0 +1,138 @@
+// Package loader 从环境变量和 DOG_CONFIG 配置文件创建看门狗, 导入时除了注册动作外没有副作用,
+// autoload 包用它监控当前进程, godog 命令用它监控其它进程
+package loader
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/bingoohuang/godog"
+	"github.com/bingoohuang/godog/busy"
+)
+
+// 配置有误时的处理方式, 由 DOG_ON_ERROR 指定
+const (
+	// OnErrorFallback 记录日志, 有误的取值使用默认值
+	OnErrorFallback = "fallback"
+	// OnErrorDisable 记录日志, 不启动看门狗
+	OnErrorDisable = "disable"
+	// OnErrorFatal 记录日志后退出程序
+	OnErrorFatal = "fatal"
+)
+
+// ErrDisabled 配置有误且 DOG_ON_ERROR=disable 时 New 返回的错误
+var ErrDisabled = errors.New("godog disabled")
+
+// Options 创建看门狗的选项, 零值表示按配置监控当前进程
+type Options struct {
+	// Target 在配置之后应用, 用于监控其它进程, 例如 godog.WithPid 或 godog.WithPidResolver, 重新加载配置时同样应用
+	Target godog.ConfigFn
+	// Actions 和 WarnActions 不为空时替代配置的 actions 和 warnActions (或 DOG_ACTIONS 和 DOG_WARN_ACTIONS)
+	Actions, WarnActions string
+}
+
+// Watchdog 从环境变量和 DOG_CONFIG 配置文件创建的看门狗, 以及它的指标和管理服务, Dog.busy 和配置文件监控
+type Watchdog struct {
+	Dog *godog.Dog
+
+	options        Options
+	path           string
+	debug          bool
+	dir            string
+	busyInterval   time.Duration
+	configInterval time.Duration
+	cancel         context.CancelFunc
+	servers        []*http.Server
+}
+
+// New 读取环境变量和配置文件创建看门狗, 不启动监控, 配置有误时按 DOG_ON_ERROR 处理,
+// disable 时返回 ErrDisabled; Options 中的动作有误时返回错误
+func New(o Options) (*Watchdog, error) {
+	warnUnknownEnvs()
+
+	var r godog.EnvReader
+	w := &Watchdog{options: o, path: os.Getenv("DOG_CONFIG")}
+	c, err := loadConfig(&r, w.path)
+	w.busyInterval = r.Duration("DOG_BUSY_INTERVAL", busy.DefaultCheckBusyInterval)
+	w.configInterval = r.Duration("DOG_CONFIG_INTERVAL", DefaultConfigInterval)
+	if err = errors.Join(err, r.Err()); err != nil && !handleConfigError(err) {
+		return nil, fmt.Errorf("%w: %w", ErrDisabled, err)
+	}
+
+	w.Dog = godog.New(w.configFns(c)...)
+	w.dir, w.debug = w.Dog.Dir, w.Dog.Debug
+	action, warn, err := buildActions(w.Dog, c, o)
+	if err != nil {
+		if o.Actions != "" || o.WarnActions != "" {
+			return nil, err
+		}
+		if !handleConfigError(fmt.Errorf("setup actions: %w", err)) {
+			return nil, fmt.Errorf("%w: %w", ErrDisabled, err)
+		}
+		return w, nil
+	}
+
+	w.Dog.Action = action
+	if warn != nil {
+		w.Dog.WarnAction = warn
+	}
+	return w, nil
+}
+
+// configFns 使用配置 c 并应用 Options.Target 的选项
+func (w *Watchdog) configFns(c *godog.Config) []godog.ConfigFn {
+	if w.options.Target == nil {
+		return []godog.ConfigFn{godog.WithConfig(c)}
+	}
+	return []godog.ConfigFn{godog.WithConfig(c), w.options.Target}
+}
+
+// Start 启动指标和管理服务, 看门狗和配置文件监控, 监控当前进程时同时监控 Dog.busy 文件,
+// 看门狗启动失败(例如目标进程不存在)时停止其它服务并返回错误
+func (w *Watchdog) Start() error {
+	var ctx context.Context
+	ctx, w.cancel = context.WithCancel(context.Background())
+	w.servers = startServers(w.Dog)
+	if w.options.Target == nil {
+		go busy.Watch(ctx, w.dir, w.debug, w.busyInterval)
+	}
+	if w.path != "" {
+		go w.watchConfig(ctx, w.configInterval)
+	}
+	if err := w.Dog.Start(); err != nil {
+		w.cancel()
+		return errors.Join(err, stopServers(context.Background(), w.servers))
+	}
+	return nil
+}
+
+// Stop 停止看门狗, Dog.busy 监控, 配置文件监控, 指标和管理服务, 关闭未完成的性能分析文件
+func (w *Watchdog) Stop(ctx context.Context) error {
+	if w.cancel != nil {
+		w.cancel()
+	}
+	return errors.Join(w.Dog.Stop(ctx), stopServers(ctx, w.servers))
+}
+
+// handleConfigError 按 DOG_ON_ERROR 处理配置错误, 返回是否继续启动看门狗
+func handleConfigError(err error) bool {
+	switch mode := cmp.Or(os.Getenv("DOG_ON_ERROR"), OnErrorFallback); mode {
+	case OnErrorFatal:
+		log.Fatalf("godog config error: %v", err)
+	case OnErrorDisable:
+		log.Printf("E! godog disabled, config error: %v", err)
+		return false
+	default:
+		if mode != OnErrorFallback {
+			log.Printf("W! unknown DOG_ON_ERROR %q, use %s", mode, OnErrorFallback)
+		}
+		log.Printf("E! godog config error, fallback to defaults: %v", err)
+	}
+	return true
+}
@@ -0,0 +1,50 @@
+package loader
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/bingoohuang/godog"
+)
+
+// startServers 设置了 DOG_METRICS_ADDR 时, 在该地址的 /metrics 输出 Prometheus 指标,
+// 设置了 DOG_ADMIN_ADDR 时, 在该地址的 /debug/godog/ 提供管理接口, 地址相同时共用一个服务
+func startServers(dog *godog.Dog) (servers []*http.Server) {
+	muxes := map[string]*http.ServeMux{}
+	handle := func(addr, pattern string, handler http.Handler) {
+		if addr == "" {
+			return
+		}
+		mux, ok := muxes[addr]
+		if !ok {
+			mux = http.NewServeMux()
+			muxes[addr] = mux
+		}
+		mux.Handle(pattern, handler)
+	}
+	handle(os.Getenv("DOG_METRICS_ADDR"), "/metrics", godog.MetricsHandler(dog))
+	handle(os.Getenv("DOG_ADMIN_ADDR"), godog.AdminPrefix, godog.AdminHandler(dog))
+
+	for addr, mux := range muxes {
+		server := &http.Server{Addr: addr, Handler: mux}
+		servers = append(servers, server)
+		go func() {
+			if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Printf("E! godog listen %s error: %v", addr, err)
+			}
+		}()
+	}
+	return servers
+}
+
+// stopServers 关闭 DOG_METRICS_ADDR 和 DOG_ADMIN_ADDR 指定的 HTTP 服务
+func stopServers(ctx context.Context, servers []*http.Server) error {
+	var errs []error
+	for _, server := range servers {
+		errs = append(errs, server.Shutdown(ctx))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,289 @@
+package godog
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/shirou/gopsutil/v4/mem"
+	"github.com/shirou/gopsutil/v4/process"
+)
+
+// DefaultCgroupRoot cgroupfs 默认挂载路径
+const DefaultCgroupRoot = "/sys/fs/cgroup"
+
+// cgroupUnlimited cgroup v1 中不限制内存时, memory.limit_in_bytes 是一个接近 int64 最大值的数
+const cgroupUnlimited = 1 << 62
+
+// ResolveLimits 解析进程可用的内存(字节)和 CPU(核数)上限,
+// 优先读取 cgroup v2 的 memory.max / cpu.max, 其次 cgroup v1 的 memory.limit_in_bytes / cpu.cfs_quota_us,
+// 没有限制时使用主机的内存和核数
+func ResolveLimits(root string, pid int) (memory uint64, cpus float64) {
+	if root == "" {
+		root = DefaultCgroupRoot
+	}
+
+	paths := readCgroupPaths(pid)
+	memory = readCgroupMemoryLimit(root, paths)
+	if memory == 0 {
+		if vm, err := mem.VirtualMemory(); err == nil {
+			memory = vm.Total
+		}
+	}
+
+	cpus = readCgroupCPULimit(root, paths)
+	if cpus == 0 {
+		cpus = float64(runtime.NumCPU())
+	}
+
+	return memory, cpus
+}
+
+// readCgroupPaths 读取 /proc/<pid>/cgroup, 返回 controller 到 cgroup 路径的映射, cgroup v2 的 controller 为空字符串
+func readCgroupPaths(pid int) map[string]string {
+	paths := map[string]string{}
+	f, err := os.Open(fmt.Sprintf("/proc/%d/cgroup", pid))
+	if err != nil {
+		return paths
+	}
+	defer f.Close()
+
+	// 每行格式 hierarchy-ID:controller-list:cgroup-path, 例如 4:memory:/foo, 0::/foo
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		if fields[1] == "" {
+			paths[""] = fields[2]
+			continue
+		}
+		for _, controller := range strings.Split(fields[1], ",") {
+			paths[controller] = fields[2]
+		}
+	}
+
+	return paths
+}
+
+// cgroupDirs 返回 controller 的候选目录, 先尝试进程所在的 cgroup 子目录, 再尝试挂载根目录(容器内通常如此)
+func cgroupDirs(root string, paths map[string]string, controller string) []string {
+	base := root
+	if controller != "" {
+		base = filepath.Join(root, controller)
+	}
+
+	var dirs []string
+	if p, ok := paths[controller]; ok && p != "/" {
+		dirs = append(dirs, filepath.Join(base, p))
+	}
+	return append(dirs, base)
+}
+
+// readCgroupFile 在候选目录中读取第一个存在的文件
+func readCgroupFile(dirs []string, name string) (string, bool) {
+	for _, dir := range dirs {
+		if data, err := os.ReadFile(filepath.Join(dir, name)); err == nil {
+			return strings.TrimSpace(string(data)), true
+		}
+	}
+	return "", false
+}
+
+// readCgroupMemoryLimit 读取 cgroup 内存上限, 没有限制时返回 0
+func readCgroupMemoryLimit(root string, paths map[string]string) uint64 {
+	// cgroup v2: memory.max 内容为 max 或字节数
+	if s, ok := readCgroupFile(cgroupDirs(root, paths, ""), "memory.max"); ok {
+		if v, err := strconv.ParseUint(s, 10, 64); err == nil {
+			return v
+		}
+		return 0
+	}
+
+	// cgroup v1
+	if s, ok := readCgroupFile(cgroupDirs(root, paths, "memory"), "memory.limit_in_bytes"); ok {
+		if v, err := strconv.ParseUint(s, 10, 64); err == nil && v < cgroupUnlimited {
+			return v
+		}
+	}
+
+	return 0
+}
+
+// readCgroupCPULimit 读取 cgroup CPU 核数上限, 没有限制时返回 0
+func readCgroupCPULimit(root string, paths map[string]string) float64 {
+	// cgroup v2: cpu.max 内容为 "$MAX $PERIOD", $MAX 为 max 时表示不限制
+	if s, ok := readCgroupFile(cgroupDirs(root, paths, ""), "cpu.max"); ok {
+		if fields := strings.Fields(s); len(fields) == 2 {
+			return cpuQuota(fields[0], fields[1])
+		}
+		return 0
+	}
+
+	// cgroup v1: cpu.cfs_quota_us 为 -1 时表示不限制
+	dirs := append(cgroupDirs(root, paths, "cpu"), cgroupDirs(root, paths, "cpu,cpuacct")...)
+	quota, ok1 := readCgroupFile(dirs, "cpu.cfs_quota_us")
+	period, ok2 := readCgroupFile(dirs, "cpu.cfs_period_us")
+	if ok1 && ok2 {
+		return cpuQuota(quota, period)
+	}
+
+	return 0
+}
+
+func cpuQuota(quota, period string) float64 {
+	q, err1 := strconv.ParseFloat(quota, 64)
+	p, err2 := strconv.ParseFloat(period, 64)
+	if err1 != nil || err2 != nil || q <= 0 || p <= 0 {
+		return 0
+	}
+	return q / p
+}
+
+// readCgroupMemoryUsage 读取 cgroup 内存用量(包含 page cache 和子进程), 即 OOM killer 判断依据
+func readCgroupMemoryUsage(root string, paths map[string]string) (uint64, error) {
+	s, ok := readCgroupFile(cgroupDirs(root, paths, ""), "memory.current")
+	if !ok {
+		if s, ok = readCgroupFile(cgroupDirs(root, paths, "memory"), "memory.usage_in_bytes"); !ok {
+			return 0, fmt.Errorf("memory.current or memory.usage_in_bytes not found in %s", root)
+		}
+	}
+
+	return strconv.ParseUint(s, 10, 64)
+}
+
+// cgroupMemoryStatKeys 触发时记录的 memory.stat 字段, cgroup v2 为 anon/file 等, v1 为 rss/cache 等
+var cgroupMemoryStatKeys = map[string]bool{
+	"anon": true, "file": true, "kernel": true, "sock": true, "shmem": true,
+	"rss": true, "cache": true, "mapped_file": true,
+}
+
+// readCgroupMemoryStat 读取 memory.stat 中的主要字段
+func readCgroupMemoryStat(root string, paths map[string]string) map[string]uint64 {
+	s, ok := readCgroupFile(cgroupDirs(root, paths, ""), "memory.stat")
+	if !ok {
+		if s, ok = readCgroupFile(cgroupDirs(root, paths, "memory"), "memory.stat"); !ok {
+			return nil
+		}
+	}
+
+	stat := parseCgroupKeyValues(s)
+	for k := range stat {
+		if !cgroupMemoryStatKeys[k] {
+			delete(stat, k)
+		}
+	}
+	return stat
+}
+
+// CgroupCPUStat cgroup cpu.stat 中的 CFS 限流统计
+type CgroupCPUStat struct {
+	// Periods 调度周期数
+	Periods uint64 `json:"periods"`
+	// Throttled 被限流的周期数
+	Throttled uint64 `json:"throttled"`
+	// ThrottledTime 被限流的总时间
+	ThrottledTime time.Duration `json:"throttledTime"`
+}
+
+// readCgroupCPUStat 读取 cpu.stat, cgroup v2 为 nr_periods/nr_throttled/throttled_usec, v1 为 nr_periods/nr_throttled/throttled_time(纳秒)
+func readCgroupCPUStat(root string, paths map[string]string) (s CgroupCPUStat, err error) {
+	content, ok := readCgroupFile(cgroupDirs(root, paths, ""), "cpu.stat")
+	v2 := ok && strings.Contains(content, "throttled_usec")
+	if !v2 {
+		dirs := append(cgroupDirs(root, paths, "cpu"), cgroupDirs(root, paths, "cpu,cpuacct")...)
+		if content, ok = readCgroupFile(dirs, "cpu.stat"); !ok {
+			return s, fmt.Errorf("cpu.stat not found in %s", root)
+		}
+	}
+
+	kv := parseCgroupKeyValues(content)
+	s.Periods = kv["nr_periods"]
+	s.Throttled = kv["nr_throttled"]
+	if v2 {
+		s.ThrottledTime = time.Duration(kv["throttled_usec"]) * time.Microsecond
+	} else {
+		s.ThrottledTime = time.Duration(kv["throttled_time"])
+	}
+	return s, nil
+}
+
+// parseCgroupKeyValues 解析每行 "key value" 形式的 cgroup 文件
+func parseCgroupKeyValues(content string) map[string]uint64 {
+	kv := map[string]uint64{}
+	for _, line := range strings.Split(content, "\n") {
+		if fields := strings.Fields(line); len(fields) == 2 {
+			if v, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+				kv[fields[0]] = v
+			}
+		}
+	}
+	return kv
+}
+
+func (w *Dog) statCgroupMemory() statFn {
+	paths := readCgroupPaths(w.Pid)
+	return func(_ *process.Process, state *thresholdState) (debugMessage string) {
+		usage, err := readCgroupMemoryUsage(w.CgroupRoot, paths)
+		if err != nil {
+			if w.Debug {
+				log.Printf("E! read cgroup memory usage error: %v", err)
+			}
+			return
+		}
+
+		if state.exceeds(usage) {
+			state.Detail = readCgroupMemoryStat(w.CgroupRoot, paths)
+		}
+		state.setReached(w.Debug, usage)
+		if w.Debug {
+			debugMessage = fmt.Sprintf("cgroup memory: %s", humanize.IBytes(usage))
+		}
+		return
+	}
+}
+
+// statThrottled 创建读取 CFS 限流增量的 statFn, timed 为 true 时取限流时间增量, 否则取限流周期数增量
+func (w *Dog) statThrottled(timed bool) statFn {
+	paths := readCgroupPaths(w.Pid)
+	var prev *CgroupCPUStat
+	return func(_ *process.Process, state *thresholdState) (debugMessage string) {
+		s, err := readCgroupCPUStat(w.CgroupRoot, paths)
+		if err != nil {
+			if w.Debug {
+				log.Printf("E! read cgroup cpu.stat error: %v", err)
+			}
+			return
+		}
+
+		// 首次采样只记录基准值
+		last := prev
+		if prev = &s; last == nil {
+			return
+		}
+
+		delta := CgroupCPUStat{
+			Periods:       s.Periods - last.Periods,
+			Throttled:     s.Throttled - last.Throttled,
+			ThrottledTime: s.ThrottledTime - last.ThrottledTime,
+		}
+		state.Detail = delta
+		if timed {
+			state.setReached(w.Debug, uint64(delta.ThrottledTime))
+		} else {
+			state.setReached(w.Debug, delta.Throttled)
+		}
+		if w.Debug {
+			debugMessage = fmt.Sprintf("throttled: %d/%d periods, %s", delta.Throttled, delta.Periods, delta.ThrottledTime)
+		}
+		return
+	}
+}
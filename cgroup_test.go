@@ -0,0 +1,140 @@
+package godog
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCgroupFS 在临时目录中创建 cgroupfs, files 的键为相对挂载根目录的路径
+func writeCgroupFS(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestReadCgroupMemoryLimit(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		paths map[string]string
+		want  uint64
+	}{
+		{"v2 root", map[string]string{"memory.max": "536870912"}, nil, 512 << 20},
+		{"v2 unlimited", map[string]string{"memory.max": "max"}, nil, 0},
+		{"v2 sub cgroup", map[string]string{
+			"memory.max":     "max",
+			"app/memory.max": "1073741824",
+		}, map[string]string{"": "/app"}, 1 << 30},
+		{"v2 sub cgroup missing, fallback to root", map[string]string{"memory.max": "1048576"},
+			map[string]string{"": "/app"}, 1 << 20},
+		{"v1", map[string]string{"memory/memory.limit_in_bytes": "268435456"}, nil, 256 << 20},
+		{"v1 sub cgroup", map[string]string{
+			"memory/memory.limit_in_bytes":        "9223372036854771712",
+			"memory/docker/memory.limit_in_bytes": "134217728",
+		}, map[string]string{"memory": "/docker"}, 128 << 20},
+		{"v1 unlimited", map[string]string{"memory/memory.limit_in_bytes": "9223372036854771712"}, nil, 0},
+		{"none", nil, nil, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := writeCgroupFS(t, tt.files)
+			if got := readCgroupMemoryLimit(root, tt.paths); got != tt.want {
+				t.Errorf("readCgroupMemoryLimit() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadCgroupCPULimit(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		paths map[string]string
+		want  float64
+	}{
+		{"v2", map[string]string{"cpu.max": "150000 100000"}, nil, 1.5},
+		{"v2 unlimited", map[string]string{"cpu.max": "max 100000"}, nil, 0},
+		{"v2 sub cgroup", map[string]string{
+			"cpu.max":     "max 100000",
+			"app/cpu.max": "200000 100000",
+		}, map[string]string{"": "/app"}, 2},
+		{"v2 malformed", map[string]string{"cpu.max": "100000"}, nil, 0},
+		{"v1 cpu", map[string]string{
+			"cpu/cpu.cfs_quota_us":  "50000",
+			"cpu/cpu.cfs_period_us": "100000",
+		}, nil, 0.5},
+		{"v1 cpu,cpuacct", map[string]string{
+			"cpu,cpuacct/cpu.cfs_quota_us":  "400000",
+			"cpu,cpuacct/cpu.cfs_period_us": "100000",
+		}, nil, 4},
+		{"v1 unlimited", map[string]string{
+			"cpu/cpu.cfs_quota_us":  "-1",
+			"cpu/cpu.cfs_period_us": "100000",
+		}, nil, 0},
+		{"v1 missing period", map[string]string{"cpu/cpu.cfs_quota_us": "50000"}, nil, 0},
+		{"none", nil, nil, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := writeCgroupFS(t, tt.files)
+			if got := readCgroupCPULimit(root, tt.paths); got != tt.want {
+				t.Errorf("readCgroupCPULimit() = %g, want %g", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadCgroupCPUStat(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		paths   map[string]string
+		want    CgroupCPUStat
+		wantErr bool
+	}{
+		{"v2", map[string]string{
+			"cpu.stat": "usage_usec 1000\nnr_periods 10\nnr_throttled 3\nthrottled_usec 2500",
+		}, nil, CgroupCPUStat{Periods: 10, Throttled: 3, ThrottledTime: 2500 * time.Microsecond}, false},
+		{"v2 sub cgroup", map[string]string{
+			"cpu.stat":     "nr_periods 1\nnr_throttled 1\nthrottled_usec 1",
+			"app/cpu.stat": "nr_periods 20\nnr_throttled 5\nthrottled_usec 1000",
+		}, map[string]string{"": "/app"}, CgroupCPUStat{Periods: 20, Throttled: 5, ThrottledTime: time.Millisecond}, false},
+		{"v1 cpu", map[string]string{
+			"cpu/cpu.stat": "nr_periods 7\nnr_throttled 2\nthrottled_time 3000000",
+		}, nil, CgroupCPUStat{Periods: 7, Throttled: 2, ThrottledTime: 3 * time.Millisecond}, false},
+		{"v1 cpu,cpuacct", map[string]string{
+			"cpu,cpuacct/cpu.stat": "nr_periods 4\nnr_throttled 1\nthrottled_time 500",
+		}, nil, CgroupCPUStat{Periods: 4, Throttled: 1, ThrottledTime: 500}, false},
+		{"v2 without cpu controller falls back to v1", map[string]string{
+			"cpu.stat":     "usage_usec 1000",
+			"cpu/cpu.stat": "nr_periods 9\nnr_throttled 0\nthrottled_time 0",
+		}, nil, CgroupCPUStat{Periods: 9}, false},
+		{"none", nil, nil, CgroupCPUStat{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := writeCgroupFS(t, tt.files)
+			got, err := readCgroupCPUStat(root, tt.paths)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readCgroupCPUStat() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("readCgroupCPUStat() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
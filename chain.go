@@ -0,0 +1,171 @@
+package godog
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrHandled Step 返回该错误表示问题已处理, Chain 不再执行后续动作
+var ErrHandled = errors.New("handled")
+
+// StepAction 可以报告执行结果的动作, Chain 据此决定继续还是中止, 返回的 reasons 会传给下一步
+type StepAction interface {
+	Action
+	Step(dir string, debug bool, reasons []ReasonItem) ([]ReasonItem, error)
+}
+
+// Chain 依次执行动作, 某一步出错时默认继续执行后续动作, 使用 AbortOnError 包装的动作出错时中止
+func Chain(actions ...Action) Action {
+	return chainAction(actions)
+}
+
+type chainAction []Action
+
+func (c chainAction) DoAction(dir string, debug bool, reasons []ReasonItem) {
+	for i, action := range c {
+		step, ok := action.(StepAction)
+		if !ok {
+			action.DoAction(dir, debug, reasons)
+			continue
+		}
+
+		next, err := step.Step(dir, debug, reasons)
+		if next != nil {
+			reasons = next
+		}
+		if errors.Is(err, ErrHandled) {
+			if debug {
+				log.Printf("godog action chain handled at step %d", i)
+			}
+			return
+		}
+		if err != nil {
+			log.Printf("E! godog action chain step %d error: %v", i, err)
+			if _, abort := action.(abortOnError); abort {
+				return
+			}
+		}
+	}
+}
+
+// AbortOnError 包装动作, 在 Chain 中出错时中止后续动作
+func AbortOnError(a Action) Action {
+	if step, ok := a.(StepAction); ok {
+		return abortOnError{StepAction: step}
+	}
+	return a
+}
+
+type abortOnError struct{ StepAction }
+
+// ActionFactory 根据 Dog 创建动作
+type ActionFactory func(d *Dog) (Action, error)
+
+var (
+	actionFactories     = map[string]ActionFactory{}
+	actionFactoriesLock sync.RWMutex
+)
+
+// RegisterAction 注册命名的动作, 同名时覆盖
+func RegisterAction(name string, factory ActionFactory) {
+	actionFactoriesLock.Lock()
+	defer actionFactoriesLock.Unlock()
+
+	actionFactories[name] = factory
+}
+
+// RegisteredActions 返回已注册的动作名称
+func RegisteredActions() []string {
+	actionFactoriesLock.RLock()
+	defer actionFactoriesLock.RUnlock()
+
+	names := make([]string, 0, len(actionFactories))
+	for name := range actionFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseActions 根据逗号分隔的动作名称创建动作链, 例如 log,webhook!,exit, 名称以 ! 结尾时出错中止
+func ParseActions(d *Dog, names string) (Action, error) {
+	var actions []Action
+	for _, name := range strings.Split(names, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+
+		abort := strings.HasSuffix(name, "!")
+		name = strings.TrimSuffix(name, "!")
+
+		actionFactoriesLock.RLock()
+		factory, ok := actionFactories[name]
+		actionFactoriesLock.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("unknown action %q, registered: %s", name, strings.Join(RegisteredActions(), ","))
+		}
+
+		action, err := factory(d)
+		if err != nil {
+			return nil, fmt.Errorf("create action %s: %w", name, err)
+		}
+		if abort {
+			action = AbortOnError(action)
+		}
+		actions = append(actions, action)
+	}
+
+	if len(actions) == 0 {
+		return nil, fmt.Errorf("no action in %q", names)
+	}
+	if len(actions) == 1 {
+		return actions[0], nil
+	}
+	return Chain(actions...), nil
+}
+
+// ProfileReason 动作生成的性能分析文件追加到 reasons 中时使用的类型
+const ProfileReason ThresholdType = "Profile"
+
+// LogAction 只打印日志的动作
+var LogAction = func(dir string, debug bool, reasons []ReasonItem) {
+	log.Printf("godog reach threshold, reason: %v", reasons)
+}
+
+// goroutineProfileAction 生成协程性能分析文件
+type goroutineProfileAction struct{ pid int }
+
+func (a goroutineProfileAction) DoAction(dir string, debug bool, reasons []ReasonItem) {
+	_, _ = a.Step(dir, debug, reasons)
+}
+
+func (a goroutineProfileAction) Step(dir string, debug bool, reasons []ReasonItem) ([]ReasonItem, error) {
+	p, err := CreateGoroutineProfile(dir, a.pid)
+	if err != nil {
+		return reasons, err
+	}
+
+	return appendRecord(reasons, ReasonItem{
+		Type:    ProfileReason,
+		Reason:  "协程性能分析",
+		Profile: p.ProfileName(),
+	}), nil
+}
+
+func init() {
+	RegisterAction("log", func(*Dog) (Action, error) { return ActionFn(LogAction), nil })
+	RegisterAction("exit", func(*Dog) (Action, error) { return ActionFn(DefaultAction), nil })
+	RegisterAction("signal", func(*Dog) (Action, error) { return &SignalAction{}, nil })
+	RegisterAction("graceful", func(d *Dog) (Action, error) { return d.GracefulExitAction(), nil })
+	RegisterAction("goroutine", func(d *Dog) (Action, error) {
+		if d.Pid != os.Getpid() {
+			return nil, errors.New("goroutine profile only works for the current process")
+		}
+		return goroutineProfileAction{pid: d.Pid}, nil
+	})
+}
@@ -2,12 +2,38 @@ package main
 
 import (
 	"flag"
+	"log"
+	"os"
 
-	_ "github.com/bingoohuang/godog/autoload"
+	"github.com/bingoohuang/godog/autoload/loader"
+	_ "github.com/joho/godotenv/autoload"
 )
 
 func main() {
 	flag.Parse()
+
+	switch flag.Arg(0) {
+	case "watch":
+		if err := watch(flag.Args()[1:]); err != nil {
+			log.Fatalf("godog watch error: %v", err)
+		}
+		return
+	case "run":
+		code, err := run(flag.Args()[1:])
+		if err != nil {
+			log.Printf("E! godog run error: %v", err)
+			code = max(code, 1)
+		}
+		os.Exit(code)
+	}
+
+	// 与导入 autoload 相同, 监控自身; watch 和 run 不监控自身, 因此不导入 autoload
+	if w, err := loader.New(loader.Options{}); err == nil {
+		if err := w.Start(); err != nil {
+			log.Printf("E! godog start error: %v", err)
+		}
+	}
+
 	cgoDemo()
 
 	select {}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"flag"
+
+	"github.com/bingoohuang/godog"
+	"github.com/bingoohuang/godog/autoload/loader"
+)
+
+// run 作为监护进程启动子进程: godog run [flags] -- cmd args, 转发信号, 超标时停止子进程,
+// 超标或退出后按 --restart 策略重启, 返回子进程最后的退出码
+func run(args []string) (int, error) {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	restart := fs.String("restart", string(godog.RestartOnFailure), "restart policy: always, on-failure or never")
+	backoff := fs.Duration("backoff", godog.DefaultRestartBackoff, "wait before the first restart, doubled on each restart")
+	maxBackoff := fs.Duration("max-backoff", godog.DefaultMaxRestartBackoff, "max wait before a restart")
+	maxRestarts := fs.Int("max-restarts", 5, "max restarts within --restart-window before giving up, 0 means unlimited")
+	window := fs.Duration("restart-window", godog.DefaultRestartWindow, "time window of --max-restarts")
+	actions := fs.String("action", "log", "comma separated actions when thresholds are reached, run while stopping the child, e.g. log,webhook")
+	if err := fs.Parse(args); err != nil {
+		return 2, err
+	}
+
+	s := &godog.Supervisor{
+		Command:       fs.Args(),
+		Restart:       godog.RestartPolicy(*restart),
+		Backoff:       *backoff,
+		MaxBackoff:    *maxBackoff,
+		MaxRestarts:   *maxRestarts,
+		RestartWindow: *window,
+	}
+	// 不监控 godog 自身, 所有子进程共用一个看门狗, 只能用于当前进程的动作(例如 goroutine, free-os-memory)在这里报错
+	w, err := loader.New(loader.Options{Target: godog.WithPidResolver(s.ChildPid), Actions: *actions})
+	if err != nil {
+		return 2, err
+	}
+	// 终止子进程的信号序列与 signal 动作相同, 见 DOG_SIGNALS 和 DOG_SIGNAL_GROUP
+	stop, err := godog.ParseActions(nil, "signal")
+	if err != nil {
+		return 2, err
+	}
+	s.Stop, _ = stop.(*godog.SignalAction)
+
+	if err := w.Start(); err != nil {
+		return 1, err
+	}
+	defer w.Stop(context.Background())
+
+	s.Dog = w.Dog
+	return s.Run(context.Background())
+}
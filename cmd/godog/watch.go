@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/bingoohuang/godog"
+	"github.com/bingoohuang/godog/autoload/loader"
+)
+
+// watch 监控其它进程: godog watch --pid 1234 | --pidfile /run/app.pid | --name nginx,
+// 阈值等配置与 autoload 相同(环境变量或 DOG_CONFIG), 超标时的动作由 --action 指定, 不会退出 godog 自身,
+// 设置了 DOG_METRICS_ADDR 或 DOG_ADMIN_ADDR 时输出目标进程的指标和管理接口
+func watch(args []string) error {
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	pid := fs.Int("pid", 0, "pid of the target process")
+	pidfile := fs.String("pidfile", "", "pidfile of the target process, re-read after the target restarts")
+	name := fs.String("name", "", "name of the target process, re-matched after the target restarts")
+	actions := fs.String("action", "log", "comma separated actions when thresholds are reached, e.g. log,exec,webhook")
+	warnActions := fs.String("warn-action", "log", "comma separated actions when warn thresholds are reached")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var target godog.ConfigFn
+	switch {
+	case *pid > 0 && *pidfile == "" && *name == "":
+		target = godog.WithPid(*pid)
+	case *pid == 0 && *pidfile != "" && *name == "":
+		target = godog.WithPidResolver(godog.PidFile(*pidfile))
+	case *pid == 0 && *pidfile == "" && *name != "":
+		target = godog.WithPidResolver(godog.ProcessName(*name))
+	default:
+		return errors.New("exactly one of --pid, --pidfile and --name is required")
+	}
+
+	w, err := loader.New(loader.Options{Target: target, Actions: *actions, WarnActions: *warnActions})
+	if err != nil {
+		return err
+	}
+	if err := w.Start(); err != nil {
+		return err
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	<-ctx.Done()
+	return w.Stop(context.Background())
+}
@@ -1,6 +1,10 @@
 package godog
 
 import (
+	"cmp"
+	"errors"
+	"fmt"
+	"maps"
 	"os"
 	"runtime"
 	"time"
@@ -8,20 +12,92 @@ import (
 
 type Config struct {
 	Pid int
+	// PidResolver 不为空时通过它查找要监控的进程, 目标进程退出后重新查找, 忽略 Pid
+	PidResolver PidResolver
 
 	// RSSThreshold RSS 上限
 	RSSThreshold uint64
+	// RSSThresholdPercent RSS 上限占内存上限 MemoryLimit 的百分比, 大于 0 时覆盖 RSSThreshold
+	RSSThresholdPercent float64
 
 	// CPUPercentThreshold 上限
 	CPUPercentThreshold uint64
+	// CPUThresholdPercent CPU 上限占 CPU 核数上限 CPULimit 的百分比, 大于 0 时覆盖 CPUPercentThreshold
+	CPUThresholdPercent float64
+	// ProcessTree 为 true 时 RSS 和 CPU 为目标进程及其所有子孙进程之和, 每次采样时重新查找子孙进程
+	ProcessTree bool
+
+	// CgroupRoot cgroupfs 挂载路径, 默认 /sys/fs/cgroup
+	CgroupRoot string
+	// MemoryLimit 内存上限, 为 0 时从 cgroup 解析, 没有限制时为主机内存
+	MemoryLimit uint64
+	// CPULimit CPU 核数上限, 为 0 时从 cgroup 解析, 没有限制时为主机核数
+	CPULimit float64
+	// resolvedMemoryLimit 和 resolvedCPULimit 按 Pid 从 cgroup 解析的上限, 目标进程变化时重新解析
+	resolvedMemoryLimit uint64
+	resolvedCPULimit    float64
+	// CgroupMemoryThreshold cgroup 内存用量上限, 0 表示不检查
+	CgroupMemoryThreshold uint64
+	// CgroupMemoryThresholdPercent cgroup 内存用量上限占内存上限 MemoryLimit 的百分比, 大于 0 时覆盖 CgroupMemoryThreshold
+	CgroupMemoryThresholdPercent float64
+	// ThrottledThreshold 采样间隔内 CFS 限流周期数上限, 0 表示不检查
+	ThrottledThreshold uint64
+	// ThrottledTimeThreshold 采样间隔内 CFS 限流时间上限, 0 表示不检查
+	ThrottledTimeThreshold time.Duration
+
+	// FDThreshold 打开文件描述符数上限, 0 表示不检查
+	FDThreshold uint64
+	// ThreadsThreshold 线程数上限, 0 表示不检查
+	ThreadsThreshold uint64
+	// GoroutineThreshold 协程数上限, 0 表示不检查, 仅在观察自身进程时生效
+	GoroutineThreshold uint64
+
+	// 以下为 runtime/metrics 指标上限, 0 表示不检查, 仅在观察自身进程时生效
+
+	// HeapLiveThreshold 存活堆字节数上限
+	HeapLiveThreshold uint64
+	// HeapObjectsThreshold 堆对象个数上限
+	HeapObjectsThreshold uint64
+	// StacksThreshold 协程栈字节数上限
+	StacksThreshold uint64
+	// HeapFreeThreshold 已释放但尚未归还操作系统的堆字节数上限
+	HeapFreeThreshold uint64
+	// GCCPUThreshold 采样间隔内 GC 占用 CPU 时间百分比上限
+	GCCPUThreshold uint64
+	// GCPauseThreshold 采样间隔内 GC 暂停时间 p99 上限
+	GCPauseThreshold time.Duration
+
+	// TrendHorizon 大于 0 时检测 RSS 和存活堆的增长趋势, 预计在该时间内超标时触发
+	TrendHorizon time.Duration
+	// TrendWindow 趋势检测的采样窗口大小, 默认 DefaultTrendWindow
+	TrendWindow int
+
 	// Interval 检查间隔
 	Interval time.Duration
 	// Jitter 间隔时间附加随机抖动
 	Jitter time.Duration
 	// Times 连续多少次
 	Times int
-	// Action 采取的动作
+	// Policies 按阈值类型设置的判定策略, 未设置的类型连续 Times 次超标即触发
+	Policies map[ThresholdType]Policy
+	// WarnThresholds 按阈值类型设置的警告级别上限, 超标时触发 WarnAction
+	WarnThresholds map[ThresholdType]uint64
+	// Cooldown 大于 0 时, 触发后在冷却时间内不再触发, 连续触发时冷却时间翻倍, 指标恢复正常后重置
+	Cooldown time.Duration
+	// MaxCooldown 冷却时间翻倍的上限, 默认 DefaultMaxCooldown
+	MaxCooldown time.Duration
+	// MaxFires 大于 0 时, 每个阈值在 FireWindow 内最多触发的次数
+	MaxFires int
+	// FireWindow 限制触发次数的时间窗口, 默认 DefaultFireWindow
+	FireWindow time.Duration
+	// Action 采取的动作, 默认 DefaultAction, 设置了 ShutdownTimeout 时默认 Dog.GracefulExitAction
 	Action Action
+	// WarnAction 警告级别超标时采取的动作, 默认 DefaultWarnAction 只打印日志
+	WarnAction Action
+	// ShutdownTimeout 优雅退出时等待关闭钩子的时间
+	ShutdownTimeout time.Duration
+	// ExitCode 优雅退出的退出码, 为 nil 时为 DefaultExitCode, 可以指定为 0
+	ExitCode *int
 	// Debug 调试模式
 	Debug bool
 
@@ -29,16 +105,156 @@ type Config struct {
 	Dir string
 }
 
+// Policy 超标判定策略
+type Policy struct {
+	// Times 超标次数, 0 时使用 Config.Times
+	Times int
+	// Window 大于 Times 时, 最近 Window 次采样中有 Times 次超标即触发, 否则要求连续 Times 次超标
+	Window int
+	// Clear 滞回下限, 大于 0 且小于阈值时, 超标后采样值回落到 Clear 及以下才算恢复
+	Clear uint64
+}
+
 const (
 	DefaultInterval     = time.Minute
 	DefaultTimes        = 5
 	DefaultRSSThreshold = 256 * 1024 * 1024 // 256 M
 	DefaultJitter       = 10 * time.Second
+	// DefaultExitCode 优雅退出的默认退出码
+	DefaultExitCode = 1
 )
 
 var DefaultCPUThreshold = uint64(50 * runtime.NumCPU())
 
-func createConfig(options []ConfigFn) *Config {
+// Validate 检查配置是否有效, 返回全部错误, 零值表示使用默认值
+func (c *Config) Validate() error { return c.validate(false) }
+
+// Sanitize 与 Validate 相同, 同时将取值有误的字段恢复为默认值, 其它字段保持不变
+func (c *Config) Sanitize() error { return c.validate(true) }
+
+func (c *Config) validate(fix bool) error {
+	var errs []error
+	// check 在 ok 为 false 时记录错误, fix 时调用 reset 恢复该字段
+	check := func(ok bool, reset func(), format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+			if fix {
+				reset()
+			}
+		}
+	}
+
+	check(c.Pid >= 0, func() { c.Pid = 0 }, "invalid pid %d", c.Pid)
+	check(c.Interval >= 0, func() { c.Interval = DefaultInterval }, "interval %s is negative", c.Interval)
+	check(c.Jitter >= 0, func() { c.Jitter = 0 }, "jitter %s is negative", c.Jitter)
+	// 默认的抖动在检查间隔较短时自动缩短, 见 createConfig
+	interval := cmp.Or(max(c.Interval, 0), DefaultInterval)
+	check(c.Jitter <= interval || c.Jitter == DefaultJitter, func() { c.Jitter = interval },
+		"jitter %s is larger than interval %s", c.Jitter, interval)
+	check(c.Times >= 0, func() { c.Times = DefaultTimes }, "times %d is negative", c.Times)
+	check(c.RSSThresholdPercent >= 0 && c.RSSThresholdPercent <= 100, func() { c.RSSThresholdPercent = 0 },
+		"RSS threshold percent %g is out of (0, 100]", c.RSSThresholdPercent)
+	check(c.CgroupMemoryThresholdPercent >= 0 && c.CgroupMemoryThresholdPercent <= 100, func() { c.CgroupMemoryThresholdPercent = 0 },
+		"cgroup memory threshold percent %g is out of (0, 100]", c.CgroupMemoryThresholdPercent)
+	check(c.CPUThresholdPercent >= 0, func() { c.CPUThresholdPercent = 0 },
+		"CPU threshold percent %g is negative", c.CPUThresholdPercent)
+	check(c.TrendHorizon >= 0, func() { c.TrendHorizon = 0 }, "trend horizon %s is negative", c.TrendHorizon)
+	check(c.TrendWindow >= 0, func() { c.TrendWindow = 0 }, "trend window %d is negative", c.TrendWindow)
+	check(c.Cooldown >= 0, func() { c.Cooldown = 0 }, "cooldown %s is negative", c.Cooldown)
+	check(c.MaxCooldown >= 0, func() { c.MaxCooldown = 0 }, "max cooldown %s is negative", c.MaxCooldown)
+	check(c.MaxFires >= 0, func() { c.MaxFires = 0 }, "max fires %d is negative", c.MaxFires)
+	check(c.FireWindow >= 0, func() { c.FireWindow = 0 }, "fire window %s is negative", c.FireWindow)
+	check(c.ShutdownTimeout >= 0, func() { c.ShutdownTimeout = 0 }, "shutdown timeout %s is negative", c.ShutdownTimeout)
+	check(c.ExitCode == nil || *c.ExitCode >= 0 && *c.ExitCode <= 255, func() { c.ExitCode = nil },
+		"exit code %d is out of [0, 255]", c.exitCode())
+
+	// 删除有误的条目前复制 map, 避免修改共享的 map
+	policies, warns := c.Policies, c.WarnThresholds
+	var policiesCloned, warnsCloned bool
+	clonePolicies := func() {
+		if !policiesCloned {
+			c.Policies, policiesCloned = maps.Clone(policies), true
+		}
+	}
+	for typ, p := range policies {
+		drop := func() {
+			clonePolicies()
+			delete(c.Policies, typ)
+		}
+		ok := p.Times >= 0 && p.Window >= 0
+		check(ok, drop, "%s policy %d/%d is negative", typ, p.Times, p.Window)
+		check(!ok || p.Window == 0 || p.Window >= p.Times, drop, "%s policy window %d is less than times %d", typ, p.Window, p.Times)
+		// 滞回下限不低于上限时不起作用, 百分比形式的上限在 createConfig 之后才能得到, 不检查
+		if threshold := c.threshold(typ); ok && p.Clear > 0 && threshold > 0 {
+			check(p.Clear < threshold, func() {
+				if _, exists := c.Policies[typ]; exists {
+					clonePolicies()
+					p.Clear = 0
+					c.Policies[typ] = p
+				}
+			}, "%s policy clear %d is not less than threshold %d", typ, p.Clear, threshold)
+		}
+	}
+	for typ, warn := range warns {
+		if threshold := c.threshold(typ); warn > 0 && threshold > 0 {
+			check(warn < threshold, func() {
+				if !warnsCloned {
+					c.WarnThresholds, warnsCloned = maps.Clone(warns), true
+				}
+				delete(c.WarnThresholds, typ)
+			}, "%s warn threshold %d is not less than threshold %d", typ, warn, threshold)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// threshold 指标的严重级别上限, 百分比形式的上限在 createConfig 之后才能得到, 返回 0
+func (c *Config) threshold(typ ThresholdType) uint64 {
+	switch typ {
+	case RSS:
+		if c.RSSThresholdPercent > 0 {
+			return 0
+		}
+		return c.RSSThreshold
+	case CPU:
+		if c.CPUThresholdPercent > 0 {
+			return 0
+		}
+		return c.CPUPercentThreshold
+	case CgroupMemory:
+		if c.CgroupMemoryThresholdPercent > 0 {
+			return 0
+		}
+		return c.CgroupMemoryThreshold
+	case Throttled:
+		return c.ThrottledThreshold
+	case ThrottledTime:
+		return uint64(c.ThrottledTimeThreshold)
+	case FD:
+		return c.FDThreshold
+	case Threads:
+		return c.ThreadsThreshold
+	case Goroutines:
+		return c.GoroutineThreshold
+	case HeapLive:
+		return c.HeapLiveThreshold
+	case HeapObjects:
+		return c.HeapObjectsThreshold
+	case Stacks:
+		return c.StacksThreshold
+	case HeapFree:
+		return c.HeapFreeThreshold
+	case GCCPU:
+		return c.GCCPUThreshold
+	case GCPause:
+		return uint64(c.GCPauseThreshold)
+	}
+	return 0
+}
+
+// createConfig 按选项创建配置, 取值有误的字段恢复为默认值(见 Config.Sanitize)并返回全部错误, 之后补全默认值
+func createConfig(options []ConfigFn) (*Config, error) {
 	c := &Config{
 		RSSThreshold:        DefaultRSSThreshold,
 		CPUPercentThreshold: DefaultCPUThreshold,
@@ -49,20 +265,80 @@ func createConfig(options []ConfigFn) *Config {
 	for _, option := range options {
 		option(c)
 	}
+	err := c.Sanitize()
 
-	if c.Pid <= 0 {
+	if c.Pid <= 0 && c.PidResolver == nil {
 		c.Pid = os.Getpid()
 	}
+	if c.CgroupRoot == "" {
+		c.CgroupRoot = DefaultCgroupRoot
+	}
+	c.resolveLimits()
 	if c.Interval <= 0 {
 		c.Interval = DefaultInterval
 	}
-	if c.Times == 0 {
+	// 默认的抖动不超过检查间隔, 例如只设置了 DOG_INTERVAL=3s 时抖动为 3s
+	if c.Jitter == DefaultJitter {
+		c.Jitter = min(c.Jitter, c.Interval)
+	}
+	if c.TrendHorizon > 0 && c.TrendWindow < 2 {
+		c.TrendWindow = DefaultTrendWindow
+	}
+	if c.Cooldown > 0 && c.MaxCooldown <= 0 {
+		c.MaxCooldown = DefaultMaxCooldown
+	}
+	if c.MaxFires > 0 && c.FireWindow <= 0 {
+		c.FireWindow = DefaultFireWindow
+	}
+	if c.Times <= 0 {
 		c.Times = DefaultTimes
 	}
-	if c.Action == nil {
+	if c.Action == nil && c.ShutdownTimeout <= 0 {
 		c.Action = ActionFn(DefaultAction)
 	}
-	return c
+	if c.WarnAction == nil {
+		c.WarnAction = ActionFn(DefaultWarnAction)
+	}
+	return c, err
+}
+
+// resolveLimits 未指定的 MemoryLimit 和 CPULimit 从 Pid 所在的 cgroup 解析, 再按百分比计算阈值,
+// 复制的配置中此前解析的上限可能属于其它进程, 重新解析; Pid 为 0 (尚未找到目标进程) 时暂不解析
+func (c *Config) resolveLimits() {
+	if c.MemoryLimit == c.resolvedMemoryLimit {
+		c.MemoryLimit = 0
+	}
+	if c.CPULimit == c.resolvedCPULimit {
+		c.CPULimit = 0
+	}
+	c.resolvedMemoryLimit, c.resolvedCPULimit = 0, 0
+	if c.Pid > 0 && (c.MemoryLimit == 0 || c.CPULimit == 0) {
+		memory, cpus := ResolveLimits(c.CgroupRoot, c.Pid)
+		if c.MemoryLimit == 0 {
+			c.MemoryLimit, c.resolvedMemoryLimit = memory, memory
+		}
+		if c.CPULimit == 0 {
+			c.CPULimit, c.resolvedCPULimit = cpus, cpus
+		}
+	}
+	if c.RSSThresholdPercent > 0 {
+		c.RSSThreshold = uint64(float64(c.MemoryLimit) * c.RSSThresholdPercent / 100)
+	}
+	if c.CgroupMemoryThresholdPercent > 0 {
+		c.CgroupMemoryThreshold = uint64(float64(c.MemoryLimit) * c.CgroupMemoryThresholdPercent / 100)
+	}
+	if c.CPUThresholdPercent > 0 {
+		// CPU 百分比以单核为 100%
+		c.CPUPercentThreshold = uint64(c.CPULimit * c.CPUThresholdPercent)
+	}
+}
+
+// exitCode 优雅退出的退出码, 未指定时为 DefaultExitCode
+func (c *Config) exitCode() int {
+	if c.ExitCode == nil {
+		return DefaultExitCode
+	}
+	return *c.ExitCode
 }
 
 type ConfigFn func(c *Config)
@@ -79,6 +355,21 @@ func WithPid(pid int) ConfigFn {
 	}
 }
 
+// WithPidResolver 通过 resolver 查找要监控的进程, 目标进程退出(例如重启)后重新查找
+func WithPidResolver(resolver PidResolver) ConfigFn {
+	return func(c *Config) {
+		c.PidResolver = resolver
+		c.Pid = 0
+	}
+}
+
+// WithProcessTree RSS 和 CPU 汇总目标进程及其所有子孙进程, 超标原因中列出占用最多的子孙进程
+func WithProcessTree() ConfigFn {
+	return func(c *Config) {
+		c.ProcessTree = true
+	}
+}
+
 func WithRSSThreshold(threshold uint64) ConfigFn {
 	return func(c *Config) {
 		c.RSSThreshold = threshold
@@ -91,6 +382,176 @@ func WithCPUPercentThreshold(threshold uint64) ConfigFn {
 	}
 }
 
+// WithRSSThresholdPercent 设置 RSS 上限为内存上限(cgroup 或主机)的百分比
+func WithRSSThresholdPercent(percent float64) ConfigFn {
+	return func(c *Config) {
+		c.RSSThresholdPercent = percent
+	}
+}
+
+// WithCPUThresholdPercent 设置 CPU 上限为 CPU 核数上限(cgroup 或主机)的百分比
+func WithCPUThresholdPercent(percent float64) ConfigFn {
+	return func(c *Config) {
+		c.CPUThresholdPercent = percent
+	}
+}
+
+func WithCgroupRoot(root string) ConfigFn {
+	return func(c *Config) {
+		c.CgroupRoot = root
+	}
+}
+
+// WithCgroupMemoryThreshold 设置 cgroup 内存用量上限
+func WithCgroupMemoryThreshold(threshold uint64) ConfigFn {
+	return func(c *Config) {
+		c.CgroupMemoryThreshold = threshold
+	}
+}
+
+// WithCgroupMemoryThresholdPercent 设置 cgroup 内存用量上限为内存上限的百分比
+func WithCgroupMemoryThresholdPercent(percent float64) ConfigFn {
+	return func(c *Config) {
+		c.CgroupMemoryThresholdPercent = percent
+	}
+}
+
+// WithThrottledThreshold 设置采样间隔内 CFS 限流周期数上限
+func WithThrottledThreshold(periods uint64) ConfigFn {
+	return func(c *Config) {
+		c.ThrottledThreshold = periods
+	}
+}
+
+// WithThrottledTimeThreshold 设置采样间隔内 CFS 限流时间上限
+func WithThrottledTimeThreshold(d time.Duration) ConfigFn {
+	return func(c *Config) {
+		c.ThrottledTimeThreshold = d
+	}
+}
+
+func WithFDThreshold(threshold uint64) ConfigFn {
+	return func(c *Config) {
+		c.FDThreshold = threshold
+	}
+}
+
+func WithThreadsThreshold(threshold uint64) ConfigFn {
+	return func(c *Config) {
+		c.ThreadsThreshold = threshold
+	}
+}
+
+func WithGoroutineThreshold(threshold uint64) ConfigFn {
+	return func(c *Config) {
+		c.GoroutineThreshold = threshold
+	}
+}
+
+func WithHeapLiveThreshold(threshold uint64) ConfigFn {
+	return func(c *Config) {
+		c.HeapLiveThreshold = threshold
+	}
+}
+
+func WithHeapObjectsThreshold(threshold uint64) ConfigFn {
+	return func(c *Config) {
+		c.HeapObjectsThreshold = threshold
+	}
+}
+
+func WithStacksThreshold(threshold uint64) ConfigFn {
+	return func(c *Config) {
+		c.StacksThreshold = threshold
+	}
+}
+
+func WithHeapFreeThreshold(threshold uint64) ConfigFn {
+	return func(c *Config) {
+		c.HeapFreeThreshold = threshold
+	}
+}
+
+func WithGCCPUThreshold(percent uint64) ConfigFn {
+	return func(c *Config) {
+		c.GCCPUThreshold = percent
+	}
+}
+
+func WithGCPauseThreshold(p99 time.Duration) ConfigFn {
+	return func(c *Config) {
+		c.GCPauseThreshold = p99
+	}
+}
+
+// WithTrend 检测 RSS 和存活堆的增长趋势, 最近 window 次采样预计在 horizon 内超标时触发
+func WithTrend(window int, horizon time.Duration) ConfigFn {
+	return func(c *Config) {
+		c.TrendWindow = window
+		c.TrendHorizon = horizon
+	}
+}
+
+// WithPolicy 设置阈值类型的超标判定策略
+func WithPolicy(typ ThresholdType, p Policy) ConfigFn {
+	return func(c *Config) {
+		if c.Policies == nil {
+			c.Policies = map[ThresholdType]Policy{}
+		}
+		c.Policies[typ] = p
+	}
+}
+
+// WithWarnThreshold 设置阈值类型的警告级别上限
+func WithWarnThreshold(typ ThresholdType, threshold uint64) ConfigFn {
+	return func(c *Config) {
+		if c.WarnThresholds == nil {
+			c.WarnThresholds = map[ThresholdType]uint64{}
+		}
+		c.WarnThresholds[typ] = threshold
+	}
+}
+
+func WithWarnAction(action Action) ConfigFn {
+	return func(c *Config) {
+		c.WarnAction = action
+	}
+}
+
+// WithGracefulExit 超标时优雅退出: 取消 Dog.Context(), 在 timeout 内执行 Dog.OnShutdown 注册的关闭钩子后以 code 退出
+func WithGracefulExit(timeout time.Duration, code int) ConfigFn {
+	return func(c *Config) {
+		if timeout <= 0 {
+			timeout = DefaultShutdownTimeout
+		}
+		c.ShutdownTimeout = timeout
+		c.ExitCode = &code
+		c.Action = nil
+	}
+}
+
+func WithAction(action Action) ConfigFn {
+	return func(c *Config) {
+		c.Action = action
+	}
+}
+
+// WithCooldown 触发后在 base 时间内不再触发, 连续触发时冷却时间翻倍, 最多到 max
+func WithCooldown(base, max time.Duration) ConfigFn {
+	return func(c *Config) {
+		c.Cooldown = base
+		c.MaxCooldown = max
+	}
+}
+
+// WithMaxFires 每个阈值在 window 内最多触发 n 次
+func WithMaxFires(n int, window time.Duration) ConfigFn {
+	return func(c *Config) {
+		c.MaxFires = n
+		c.FireWindow = window
+	}
+}
+
 func WithInterval(interval, jitter time.Duration) ConfigFn {
 	return func(c *Config) {
 		c.Interval = interval
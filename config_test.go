@@ -0,0 +1,76 @@
+package godog
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr bool
+	}{
+		{"zero values use defaults", Config{}, false},
+		{"negative times", Config{Times: -1}, true},
+		{"negative interval", Config{Interval: -time.Second}, true},
+		{"jitter larger than interval", Config{Interval: time.Second, Jitter: time.Hour}, true},
+		{"jitter equals interval", Config{Interval: time.Second, Jitter: time.Second}, false},
+		{"default jitter with short interval", Config{Interval: 3 * time.Second, Jitter: DefaultJitter}, false},
+		{"jitter larger than default interval", Config{Jitter: 2 * DefaultInterval}, true},
+		{"clear below threshold", Config{RSSThreshold: 256, Policies: map[ThresholdType]Policy{RSS: {Clear: 200}}}, false},
+		{"clear not below threshold", Config{RSSThreshold: 256, Policies: map[ThresholdType]Policy{RSS: {Clear: 300}}}, true},
+		{"clear with percent threshold", Config{RSSThresholdPercent: 80, Policies: map[ThresholdType]Policy{RSS: {Clear: 300}}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.Validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewSanitizesConfig(t *testing.T) {
+	d := New(WithTimes(-1), WithInterval(-time.Second, time.Hour))
+	if d.Times != DefaultTimes {
+		t.Errorf("Times = %d, want %d", d.Times, DefaultTimes)
+	}
+	if d.Interval != DefaultInterval || d.Jitter != DefaultInterval {
+		t.Errorf("Interval, Jitter = %s, %s, want %s, %s", d.Interval, d.Jitter, DefaultInterval, DefaultInterval)
+	}
+
+	// 默认的抖动不超过较短的检查间隔
+	d = New(WithInterval(3*time.Second, DefaultJitter))
+	if d.Jitter != 3*time.Second {
+		t.Errorf("Jitter = %s, want 3s", d.Jitter)
+	}
+
+	if err := d.Reload(WithTimes(-1)); err == nil {
+		t.Error("Reload(WithTimes(-1)) want error")
+	}
+}
+
+func TestNegativeTimesNotReached(t *testing.T) {
+	d := New(WithTimes(-1), WithFDThreshold(10))
+	for _, state := range d.states {
+		if r := state.reached(d.timesOf(state), false); r.Reached {
+			t.Fatalf("%s reached without samples", state.Type)
+		}
+	}
+}
+
+func TestSanitizePolicyClear(t *testing.T) {
+	policies := map[ThresholdType]Policy{RSS: {Times: 2, Window: 3, Clear: 300}}
+	c := Config{RSSThreshold: 256, Policies: policies}
+	if err := c.Sanitize(); err == nil {
+		t.Fatal("Sanitize() want error")
+	}
+	if want := (Policy{Times: 2, Window: 3}); c.Policies[RSS] != want {
+		t.Errorf("policy = %+v, want %+v", c.Policies[RSS], want)
+	}
+	if policies[RSS].Clear != 300 {
+		t.Error("Sanitize modified the shared policies")
+	}
+}
@@ -0,0 +1,361 @@
+package godog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"gopkg.in/yaml.v3"
+)
+
+// FileConfig 配置文件(YAML 或 JSON)的内容, 取值格式与对应的环境变量相同, 空值表示不修改, 例如:
+//
+//	interval: 30s
+//	times: 3
+//	thresholds:
+//	  RSS: 80%
+//	  Goroutines: 10000
+//	warnThresholds:
+//	  RSS: 384MiB
+//	policies:
+//	  RSS: {policy: 3/5, clear: 200MiB}
+//	actions: webhook,exit
+//	webhook:
+//	  url: https://example.com/hook
+type FileConfig struct {
+	Pid        int    `yaml:"pid" json:"pid"`
+	Dir        string `yaml:"dir" json:"dir"`
+	Debug      *bool  `yaml:"debug" json:"debug"`
+	CgroupRoot string `yaml:"cgroupRoot" json:"cgroupRoot"`
+	// ProcessTree 汇总目标进程及其所有子孙进程的 RSS 和 CPU
+	ProcessTree *bool `yaml:"processTree" json:"processTree"`
+	// MemoryLimit 和 CPULimit 内存和 CPU 核数上限, 即百分比阈值的基准, 例如 2GiB 和 1.5, 为空时从 cgroup 解析
+	MemoryLimit string  `yaml:"memoryLimit" json:"memoryLimit"`
+	CPULimit    float64 `yaml:"cpuLimit" json:"cpuLimit"`
+
+	// Thresholds 各指标的上限, RSS, CPU 和 CgroupMemory 支持百分比
+	Thresholds map[ThresholdType]string `yaml:"thresholds" json:"thresholds"`
+	// WarnThresholds 各指标的警告级别上限, 不支持百分比
+	WarnThresholds map[ThresholdType]string `yaml:"warnThresholds" json:"warnThresholds"`
+	// Policies 各指标的判定策略
+	Policies map[ThresholdType]FilePolicy `yaml:"policies" json:"policies"`
+
+	TrendHorizon    string `yaml:"trendHorizon" json:"trendHorizon"`
+	TrendWindow     int    `yaml:"trendWindow" json:"trendWindow"`
+	Interval        string `yaml:"interval" json:"interval"`
+	Jitter          string `yaml:"jitter" json:"jitter"`
+	Times           int    `yaml:"times" json:"times"`
+	Cooldown        string `yaml:"cooldown" json:"cooldown"`
+	MaxCooldown     string `yaml:"maxCooldown" json:"maxCooldown"`
+	MaxFires        int    `yaml:"maxFires" json:"maxFires"`
+	FireWindow      string `yaml:"fireWindow" json:"fireWindow"`
+	ShutdownTimeout string `yaml:"shutdownTimeout" json:"shutdownTimeout"`
+	ExitCode        *int   `yaml:"exitCode" json:"exitCode"`
+
+	// Actions 和 WarnActions 逗号分隔的动作名称, 同 DOG_ACTIONS 和 DOG_WARN_ACTIONS
+	Actions     string `yaml:"actions" json:"actions"`
+	WarnActions string `yaml:"warnActions" json:"warnActions"`
+	// Webhook 和 Exec 不为空时替代对应的环境变量
+	Webhook *FileWebhook `yaml:"webhook" json:"webhook"`
+	Exec    *FileExec    `yaml:"exec" json:"exec"`
+	Signal  *FileSignal  `yaml:"signal" json:"signal"`
+}
+
+// FilePolicy 配置文件中的判定策略
+type FilePolicy struct {
+	// Policy M/N 表示最近 N 次采样中 M 次超标, 只有 M 时表示连续 M 次超标
+	Policy string `yaml:"policy" json:"policy"`
+	// Clear 滞回下限, 格式同阈值
+	Clear string `yaml:"clear" json:"clear"`
+}
+
+// FileWebhook 配置文件中的 Webhook 设置
+type FileWebhook struct {
+	URL string `yaml:"url" json:"url"`
+	// Template 请求体模板, TemplateFile 不为空时从该文件读取
+	Template     string            `yaml:"template" json:"template"`
+	TemplateFile string            `yaml:"templateFile" json:"templateFile"`
+	Headers      map[string]string `yaml:"headers" json:"headers"`
+	Timeout      string            `yaml:"timeout" json:"timeout"`
+	// Retries 为空时重试 3 次
+	Retries *int `yaml:"retries" json:"retries"`
+}
+
+// FileExec 配置文件中的执行命令设置
+type FileExec struct {
+	Command string `yaml:"command" json:"command"`
+	Timeout string `yaml:"timeout" json:"timeout"`
+	// Exit 为 false 时执行命令后不再退出
+	Exit *bool `yaml:"exit" json:"exit"`
+}
+
+// FileSignal 配置文件中的发送信号设置
+type FileSignal struct {
+	// Steps 升级序列, 例如 TERM:10s,KILL:5s, 为空时使用 DefaultSignalSteps
+	Steps string `yaml:"steps" json:"steps"`
+	// Group 为 true 时向进程组发送信号
+	Group bool `yaml:"group" json:"group"`
+	// Child 为 true 时只向占用最多的子孙进程发送信号
+	Child bool `yaml:"child" json:"child"`
+}
+
+// LoadConfigFile 读取配置文件, .json 结尾时按 JSON 解析, 否则按 YAML 解析, 不认识的字段返回错误
+func LoadConfigFile(path string) (*FileConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config %s: %w", path, err)
+	}
+
+	var f FileConfig
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&f)
+	} else {
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err = dec.Decode(&f); errors.Is(err, io.EOF) { // 空文件
+			err = nil
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse config %s: %w", path, err)
+	}
+	return &f, nil
+}
+
+// Apply 把配置文件的内容覆盖到 c 上, 任一取值有误时返回全部错误
+func (f *FileConfig) Apply(c *Config) error {
+	var errs []error
+	duration := func(name, s string, d *time.Duration) {
+		if s == "" {
+			return
+		}
+		v, err := time.ParseDuration(s)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("parse %s: %w", name, err))
+			return
+		}
+		*d = v
+	}
+	nonZero := func(v int, d *int) {
+		if v != 0 {
+			*d = v
+		}
+	}
+
+	nonZero(f.Pid, &c.Pid)
+	if f.Dir != "" {
+		c.Dir = f.Dir
+	}
+	if f.Debug != nil {
+		c.Debug = *f.Debug
+	}
+	if f.CgroupRoot != "" {
+		c.CgroupRoot = f.CgroupRoot
+	}
+	if f.ProcessTree != nil {
+		c.ProcessTree = *f.ProcessTree
+	}
+	if f.MemoryLimit != "" {
+		if v, err := humanize.ParseBytes(f.MemoryLimit); err != nil {
+			errs = append(errs, fmt.Errorf("parse memoryLimit: %w", err))
+		} else {
+			c.MemoryLimit = v
+		}
+	}
+	if f.CPULimit < 0 {
+		errs = append(errs, fmt.Errorf("cpuLimit %g is negative", f.CPULimit))
+	} else if f.CPULimit > 0 {
+		c.CPULimit = f.CPULimit
+	}
+
+	for typ, s := range f.Thresholds {
+		if err := setConfigThreshold(c, typ, s); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(f.WarnThresholds) > 0 {
+		c.WarnThresholds = maps.Clone(c.WarnThresholds)
+		if c.WarnThresholds == nil {
+			c.WarnThresholds = map[ThresholdType]uint64{}
+		}
+		for typ, s := range f.WarnThresholds {
+			if v, err := ParseThreshold(typ, s); err != nil {
+				errs = append(errs, err)
+			} else {
+				c.WarnThresholds[typ] = v
+			}
+		}
+	}
+	if len(f.Policies) > 0 {
+		c.Policies = maps.Clone(c.Policies)
+		if c.Policies == nil {
+			c.Policies = map[ThresholdType]Policy{}
+		}
+		for typ, fp := range f.Policies {
+			var p Policy
+			var err error
+			if fp.Policy != "" {
+				p.Times, p.Window, err = ParsePolicy(fp.Policy)
+			}
+			if err == nil && fp.Clear != "" {
+				p.Clear, err = ParseThreshold(typ, fp.Clear)
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("parse %s policy: %w", typ, err))
+				continue
+			}
+			c.Policies[typ] = p
+		}
+	}
+
+	duration("trendHorizon", f.TrendHorizon, &c.TrendHorizon)
+	nonZero(f.TrendWindow, &c.TrendWindow)
+	duration("interval", f.Interval, &c.Interval)
+	duration("jitter", f.Jitter, &c.Jitter)
+	nonZero(f.Times, &c.Times)
+	duration("cooldown", f.Cooldown, &c.Cooldown)
+	duration("maxCooldown", f.MaxCooldown, &c.MaxCooldown)
+	nonZero(f.MaxFires, &c.MaxFires)
+	duration("fireWindow", f.FireWindow, &c.FireWindow)
+	duration("shutdownTimeout", f.ShutdownTimeout, &c.ShutdownTimeout)
+	if f.ExitCode != nil {
+		code := *f.ExitCode
+		c.ExitCode = &code
+	}
+
+	return errors.Join(errs...)
+}
+
+// setConfigThreshold 设置指标的上限, RSS, CPU 和 CgroupMemory 支持百分比
+func setConfigThreshold(c *Config, typ ThresholdType, s string) error {
+	percent, ok, err := parsePercent(s)
+	if err != nil {
+		return fmt.Errorf("parse %s threshold: %w", typ, err)
+	}
+	if ok {
+		switch typ {
+		case RSS:
+			c.RSSThresholdPercent = percent
+		case CPU:
+			c.CPUThresholdPercent = percent
+		case CgroupMemory:
+			c.CgroupMemoryThresholdPercent = percent
+		default:
+			return fmt.Errorf("%s threshold does not support percent %q", typ, s)
+		}
+		return nil
+	}
+
+	v, err := ParseThreshold(typ, s)
+	if err != nil {
+		return err
+	}
+	switch typ {
+	case RSS:
+		c.RSSThreshold, c.RSSThresholdPercent = v, 0
+	case CPU:
+		c.CPUPercentThreshold, c.CPUThresholdPercent = v, 0
+	case CgroupMemory:
+		c.CgroupMemoryThreshold, c.CgroupMemoryThresholdPercent = v, 0
+	case Throttled:
+		c.ThrottledThreshold = v
+	case ThrottledTime:
+		c.ThrottledTimeThreshold = time.Duration(v)
+	case FD:
+		c.FDThreshold = v
+	case Threads:
+		c.ThreadsThreshold = v
+	case Goroutines:
+		c.GoroutineThreshold = v
+	case HeapLive:
+		c.HeapLiveThreshold = v
+	case HeapObjects:
+		c.HeapObjectsThreshold = v
+	case Stacks:
+		c.StacksThreshold = v
+	case HeapFree:
+		c.HeapFreeThreshold = v
+	case GCCPU:
+		c.GCCPUThreshold = v
+	case GCPause:
+		c.GCPauseThreshold = time.Duration(v)
+	default:
+		return fmt.Errorf("unknown threshold type %s", typ)
+	}
+	return nil
+}
+
+// Action 根据 Webhook 设置创建通知动作
+func (f *FileWebhook) Action(next Action) (*WebhookAction, error) {
+	a := &WebhookAction{
+		URL:      f.URL,
+		Template: f.Template,
+		Headers:  f.Headers,
+		Timeout:  DefaultWebhookTimeout,
+		Retries:  3,
+		Next:     next,
+	}
+	if a.URL == "" {
+		return nil, errors.New("webhook url is empty")
+	}
+	if f.TemplateFile != "" {
+		data, err := os.ReadFile(f.TemplateFile)
+		if err != nil {
+			return nil, fmt.Errorf("read webhook template: %w", err)
+		}
+		a.Template = string(data)
+	}
+	if f.Timeout != "" {
+		timeout, err := time.ParseDuration(f.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("parse webhook timeout: %w", err)
+		}
+		a.Timeout = timeout
+	}
+	if f.Retries != nil {
+		a.Retries = *f.Retries
+	}
+	return a, nil
+}
+
+// Action 根据执行命令设置创建动作
+func (f *FileExec) Action(next Action) (*ExecAction, error) {
+	a := &ExecAction{Command: f.Command, Timeout: DefaultExecTimeout, Next: next}
+	if a.Command == "" {
+		return nil, errors.New("exec command is empty")
+	}
+	if f.Timeout != "" {
+		timeout, err := time.ParseDuration(f.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("parse exec timeout: %w", err)
+		}
+		a.Timeout = timeout
+	}
+	if f.Exit != nil && !*f.Exit {
+		a.Next = nil
+	}
+	return a, nil
+}
+
+// Action 根据发送信号设置创建动作
+func (f *FileSignal) Action() (*SignalAction, error) {
+	a := &SignalAction{Group: f.Group, Child: f.Child}
+	if f.Steps != "" {
+		steps, err := ParseSignalSteps(f.Steps)
+		if err != nil {
+			return nil, fmt.Errorf("parse signal steps: %w", err)
+		}
+		a.Steps = steps
+	}
+	return a, nil
+}
@@ -0,0 +1,40 @@
+package godog
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileConfigLimits(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dog.yaml")
+	content := "memoryLimit: 1GiB\ncpuLimit: 1.5\nthresholds:\n  RSS: 50%\n  CPU: 50%\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := LoadConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fc Config
+	if err := f.Apply(&fc); err != nil {
+		t.Fatal(err)
+	}
+	c, err := createConfig([]ConfigFn{WithConfig(&fc)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.MemoryLimit != 1<<30 || c.CPULimit != 1.5 {
+		t.Fatalf("limits = %d, %g, want 1GiB, 1.5", c.MemoryLimit, c.CPULimit)
+	}
+	if c.RSSThreshold != 512<<20 || c.CPUPercentThreshold != 75 {
+		t.Fatalf("thresholds = %d, %d, want 512MiB, 75", c.RSSThreshold, c.CPUPercentThreshold)
+	}
+
+	for _, bad := range []FileConfig{{MemoryLimit: "lots"}, {CPULimit: -1}} {
+		if err := bad.Apply(&Config{}); err == nil {
+			t.Errorf("Apply(%+v) want error", bad)
+		}
+	}
+}
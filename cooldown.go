@@ -0,0 +1,93 @@
+package godog
+
+import "time"
+
+const (
+	// DefaultMaxCooldown 冷却时间指数退避的默认上限
+	DefaultMaxCooldown = time.Hour
+	// DefaultFireWindow 限制触发次数的默认时间窗口
+	DefaultFireWindow = time.Hour
+)
+
+// cooldown 每个阈值状态的冷却控制: 触发后在冷却时间内不再触发, 连续触发时冷却时间指数退避,
+// 并限制时间窗口内的最大触发次数, 被抑制的触发次数在下次触发时报告
+type cooldown struct {
+	base, max time.Duration
+	maxFires  int
+	window    time.Duration
+
+	current    time.Duration
+	next       time.Time
+	fires      []time.Time
+	suppressed int
+}
+
+// newCooldown 根据配置创建冷却控制, 没有配置冷却时间和最大触发次数时返回 nil
+func newCooldown(c *Config) *cooldown {
+	if c.Cooldown <= 0 && c.MaxFires <= 0 {
+		return nil
+	}
+
+	return &cooldown{
+		base:     c.Cooldown,
+		max:      max(c.MaxCooldown, c.Cooldown),
+		maxFires: c.MaxFires,
+		window:   c.FireWindow,
+	}
+}
+
+// suppressing 当前是否应抑制触发
+func (c *cooldown) suppressing(now time.Time) bool {
+	if c == nil {
+		return false
+	}
+	if now.Before(c.next) {
+		return true
+	}
+
+	if c.maxFires > 0 {
+		i := 0
+		for i < len(c.fires) && now.Sub(c.fires[i]) > c.window {
+			i++
+		}
+		c.fires = c.fires[i:]
+		return len(c.fires) >= c.maxFires
+	}
+	return false
+}
+
+// suppress 记录一次被抑制的触发
+func (c *cooldown) suppress() {
+	if c != nil {
+		c.suppressed++
+	}
+}
+
+// fire 记录一次触发, 返回此前被抑制的次数
+func (c *cooldown) fire(now time.Time) (suppressed int) {
+	if c == nil {
+		return 0
+	}
+
+	if c.base > 0 {
+		if c.current == 0 {
+			c.current = c.base
+		} else {
+			c.current = min(c.current*2, c.max)
+		}
+		c.next = now.Add(c.current)
+	}
+	if c.maxFires > 0 {
+		c.fires = append(c.fires, now)
+	}
+
+	suppressed, c.suppressed = c.suppressed, 0
+	return suppressed
+}
+
+// reset 指标恢复正常时, 冷却时间回到初始值
+func (c *cooldown) reset() {
+	if c != nil {
+		c.current = 0
+	}
+}
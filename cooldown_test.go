@@ -0,0 +1,111 @@
+package godog
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewCooldown(t *testing.T) {
+	if c := newCooldown(&Config{}); c != nil {
+		t.Fatalf("newCooldown() = %+v, want nil without cooldown and max fires", c)
+	}
+	c := newCooldown(&Config{Cooldown: time.Hour, MaxCooldown: time.Minute})
+	if c.max != time.Hour {
+		t.Fatalf("max = %s, want max cooldown not less than cooldown", c.max)
+	}
+
+	var nilCooldown *cooldown
+	if nilCooldown.suppressing(time.Now()) || nilCooldown.fire(time.Now()) != 0 {
+		t.Fatal("nil cooldown suppresses")
+	}
+	nilCooldown.suppress()
+	nilCooldown.reset()
+}
+
+func TestCooldownBackoff(t *testing.T) {
+	c := newCooldown(&Config{Cooldown: time.Minute, MaxCooldown: 3 * time.Minute})
+	now := time.Unix(0, 0)
+
+	// 每次冷却结束后立即再次触发, 冷却时间翻倍直到上限
+	for _, want := range []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute, 3 * time.Minute} {
+		if c.suppressing(now) {
+			t.Fatalf("suppressing at %s, want fire", now)
+		}
+		c.fire(now)
+		if c.current != want {
+			t.Fatalf("cooldown = %s, want %s", c.current, want)
+		}
+		if !c.suppressing(now.Add(want - time.Second)) {
+			t.Fatalf("not suppressing within cooldown %s", want)
+		}
+		now = now.Add(want)
+	}
+
+	// 指标恢复后冷却时间回到初始值
+	c.reset()
+	c.fire(now)
+	if c.current != time.Minute {
+		t.Fatalf("cooldown after reset = %s, want %s", c.current, time.Minute)
+	}
+}
+
+func TestCooldownSuppressedCount(t *testing.T) {
+	c := newCooldown(&Config{Cooldown: time.Minute})
+	now := time.Unix(0, 0)
+	if n := c.fire(now); n != 0 {
+		t.Fatalf("first fire suppressed = %d, want 0", n)
+	}
+	for i := 0; i < 3; i++ {
+		if !c.suppressing(now.Add(time.Duration(i) * time.Second)) {
+			t.Fatal("not suppressing within cooldown")
+		}
+		c.suppress()
+	}
+	if n := c.fire(now.Add(time.Minute)); n != 3 {
+		t.Fatalf("suppressed = %d, want 3", n)
+	}
+	if n := c.fire(now.Add(time.Hour)); n != 0 {
+		t.Fatalf("suppressed after report = %d, want 0", n)
+	}
+}
+
+func TestCooldownMaxFires(t *testing.T) {
+	c := newCooldown(&Config{MaxFires: 2, FireWindow: time.Hour})
+	now := time.Unix(0, 0)
+	c.fire(now)
+	c.fire(now.Add(time.Minute))
+	if !c.suppressing(now.Add(2 * time.Minute)) {
+		t.Fatal("not suppressing after max fires in window")
+	}
+	// 第一次触发移出窗口后可以再次触发
+	if c.suppressing(now.Add(time.Hour + time.Second)) {
+		t.Fatal("suppressing after the first fire left the window")
+	}
+}
+
+func TestThresholdStateCooldown(t *testing.T) {
+	state := newThresholdState(FD, 10, nil, t.TempDir(), 0)
+	state.applyPolicy(Policy{Times: 1}, DefaultTimes)
+	state.cooldown = newCooldown(&Config{Cooldown: time.Hour})
+
+	state.setReached(false, 11)
+	if r := state.reached(state.Times, false); !r.Reached {
+		t.Fatal("first exceed not reached")
+	}
+	for i := 0; i < 2; i++ {
+		state.setReached(false, 11)
+		if r := state.reached(state.Times, false); r.Reached {
+			t.Fatal("reached within cooldown")
+		}
+	}
+	if state.cooldown.suppressed != 2 {
+		t.Fatalf("suppressed = %d, want 2", state.cooldown.suppressed)
+	}
+
+	// 冷却结束后触发时报告被抑制的次数
+	state.cooldown.next = time.Now()
+	state.setReached(false, 11)
+	if r := state.reached(state.Times, false); !r.Reached || r.Suppressed != 2 {
+		t.Fatalf("reached = %t, suppressed = %d, want true, 2", r.Reached, r.Suppressed)
+	}
+}
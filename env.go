@@ -0,0 +1,118 @@
+package godog
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/dustin/go-humanize"
+)
+
+// EnvReader 读取环境变量, 取值有误时返回默认值并记录错误, 读取完成后用 Err 取出全部错误
+type EnvReader struct {
+	errs []error
+}
+
+// Err 返回读取过程中的全部错误
+func (r *EnvReader) Err() error {
+	return errors.Join(r.errs...)
+}
+
+func (r *EnvReader) fail(name string, err error) {
+	r.errs = append(r.errs, fmt.Errorf("parse env %s=%q error: %w", name, os.Getenv(name), err))
+}
+
+// fatal 有错误时退出程序, 用于兼容 GetEnv 系列函数
+func (r *EnvReader) fatal() {
+	if err := r.Err(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Size 读取容量, 例如 256MiB
+func (r *EnvReader) Size(name string, defaultValue uint64) uint64 {
+	env := os.Getenv(name)
+	if env == "" {
+		return defaultValue
+	}
+
+	val, err := humanize.ParseBytes(env)
+	if err != nil {
+		r.fail(name, err)
+		return defaultValue
+	}
+	return val
+}
+
+// Int 读取非负整数
+func (r *EnvReader) Int(name string, defaultValue uint64) uint64 {
+	env := os.Getenv(name)
+	if env == "" {
+		return defaultValue
+	}
+
+	val, err := strconv.ParseUint(env, 10, 64)
+	if err != nil {
+		r.fail(name, err)
+		return defaultValue
+	}
+	return val
+}
+
+// Duration 读取时长, 例如 10s
+func (r *EnvReader) Duration(name string, defaultValue time.Duration) time.Duration {
+	env := os.Getenv(name)
+	if env == "" {
+		return defaultValue
+	}
+
+	val, err := time.ParseDuration(env)
+	if err != nil {
+		r.fail(name, err)
+		return defaultValue
+	}
+	return val
+}
+
+// SizeOrPercent 读取容量(例如 256MiB)或百分比(例如 80%)
+func (r *EnvReader) SizeOrPercent(name string, defaultValue uint64) (size uint64, percent float64) {
+	if percent, ok := r.percent(name); ok {
+		return defaultValue, percent
+	}
+	return r.Size(name, defaultValue), 0
+}
+
+// IntOrPercent 读取整数(例如 200)或百分比(例如 90%)
+func (r *EnvReader) IntOrPercent(name string, defaultValue uint64) (val uint64, percent float64) {
+	if percent, ok := r.percent(name); ok {
+		return defaultValue, percent
+	}
+	return r.Int(name, defaultValue), 0
+}
+
+func (r *EnvReader) percent(name string) (float64, bool) {
+	val, ok, err := parsePercent(os.Getenv(name))
+	if err != nil {
+		r.fail(name, err)
+		return 0, true
+	}
+	return val, ok
+}
+
+// Policy 读取 M/N 形式的判定策略, 表示最近 N 次采样中 M 次超标, 只有 M 时表示连续 M 次超标
+func (r *EnvReader) Policy(name string) (times, window int) {
+	env := os.Getenv(name)
+	if env == "" {
+		return 0, 0
+	}
+
+	times, window, err := ParsePolicy(env)
+	if err != nil {
+		r.fail(name, err)
+		return 0, 0
+	}
+	return times, window
+}
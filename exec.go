@@ -0,0 +1,145 @@
+package godog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultExecTimeout 执行命令的默认超时时间
+	DefaultExecTimeout = 30 * time.Second
+	// maxExecOutput 记录的 stdout/stderr 最大长度
+	maxExecOutput = 64 * 1024
+)
+
+// ExecReason 执行命令的结果追加到 reasons 中时使用的类型
+const ExecReason ThresholdType = "Exec"
+
+// ExecAction 执行外部命令, 通过 stdin 传入 reasons 的 JSON, 通过环境变量传入
+// DOG_REASON_TYPE, DOG_SEVERITY, DOG_PROFILE, DOG_PID, DOG_DIR, 然后执行 Next
+type ExecAction struct {
+	// Command 通过 sh -c 执行的命令
+	Command string
+	// Timeout 超时时间, 默认 DefaultExecTimeout
+	Timeout time.Duration
+	// Next 执行命令之后的动作, 执行结果追加到 reasons 中, 为 nil 时进程不退出
+	Next Action
+}
+
+// ExecResult 执行命令的结果
+type ExecResult struct {
+	Command  string `json:"command"`
+	ExitCode int    `json:"exitCode"`
+	Error    string `json:"error,omitempty"`
+	Stdout   string `json:"stdout,omitempty"`
+	Stderr   string `json:"stderr,omitempty"`
+	Elapsed  string `json:"elapsed"`
+}
+
+func (a *ExecAction) DoAction(dir string, debug bool, reasons []ReasonItem) {
+	reasons, _ = a.Step(dir, debug, reasons)
+	if a.Next != nil {
+		a.Next.DoAction(dir, debug, reasons)
+	}
+}
+
+// Step 执行命令, 执行结果追加到 reasons 中, 作为 Chain 中的一步
+func (a *ExecAction) Step(dir string, debug bool, reasons []ReasonItem) ([]ReasonItem, error) {
+	result := a.Run(dir, reasons)
+	log.Printf("godog exec %q, exit code: %d, error: %q, stdout: %q, stderr: %q",
+		result.Command, result.ExitCode, result.Error, result.Stdout, result.Stderr)
+
+	reasons = appendRecord(reasons, ReasonItem{
+		Type:   ExecReason,
+		Reason: fmt.Sprintf("执行命令 %s", a.Command),
+		Detail: result,
+	})
+
+	if result.Error != "" {
+		return reasons, fmt.Errorf("exec %s: %s", a.Command, result.Error)
+	}
+	return reasons, nil
+}
+
+// Run 执行命令并返回结果
+func (a *ExecAction) Run(dir string, reasons []ReasonItem) ExecResult {
+	timeout := a.Timeout
+	if timeout <= 0 {
+		timeout = DefaultExecTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	result := ExecResult{Command: a.Command}
+	stdin, err := json.Marshal(reasons)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	var types, severities, profiles []string
+	for _, r := range reasons {
+		types = append(types, string(r.Type))
+		severities = append(severities, string(r.Severity))
+		if r.Profile != "" {
+			profiles = append(profiles, r.Profile)
+		}
+	}
+
+	stdout := &limitedBuffer{limit: maxExecOutput}
+	stderr := &limitedBuffer{limit: maxExecOutput}
+	cmd := exec.CommandContext(ctx, "sh", "-c", a.Command)
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.WaitDelay = time.Second
+	cmd.Env = append(os.Environ(),
+		"DOG_REASON_TYPE="+strings.Join(types, ","),
+		"DOG_SEVERITY="+strings.Join(severities, ","),
+		"DOG_PROFILE="+strings.Join(profiles, ","),
+		"DOG_PID="+strconv.Itoa(targetPid(reasons)),
+		"DOG_DIR="+dir,
+	)
+
+	start := time.Now()
+	err = cmd.Run()
+	result.Elapsed = time.Since(start).String()
+	result.Stdout = stdout.String()
+	result.Stderr = stderr.String()
+	if cmd.ProcessState != nil {
+		result.ExitCode = cmd.ProcessState.ExitCode()
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		result.Error = fmt.Sprintf("timeout after %s", timeout)
+	} else if err != nil {
+		result.Error = err.Error()
+	}
+
+	return result
+}
+
+// limitedBuffer 只保留前 limit 个字节的 Buffer
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if n := b.limit - b.Len(); n > 0 {
+		if len(p) > n {
+			b.Buffer.Write(p[:n])
+		} else {
+			b.Buffer.Write(p)
+		}
+	}
+	return len(p), nil
+}
@@ -0,0 +1,122 @@
+package godog
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/shirou/gopsutil/v4/process"
+)
+
+// FDSummary 进程打开的文件描述符摘要
+type FDSummary struct {
+	Total int `json:"total"`
+	// Kinds 按类型(socket, pipe, file, anon_inode 等)分组的数量
+	Kinds map[string]int `json:"kinds"`
+	// Top 数量最多的目标
+	Top []FDTarget `json:"top"`
+}
+
+// FDTarget 文件描述符指向的目标
+type FDTarget struct {
+	Kind   string `json:"kind"`
+	Target string `json:"target"`
+	Count  int    `json:"count"`
+}
+
+// DefaultFDTopN FDSummary.Top 的默认条数
+const DefaultFDTopN = 10
+
+// ReadFDSummary 读取 /proc/<pid>/fd, 汇总文件描述符的目标
+func ReadFDSummary(pid, topN int) (*FDSummary, error) {
+	dir := fmt.Sprintf("/proc/%d/fd", pid)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read dir %s: %w", dir, err)
+	}
+
+	s := &FDSummary{Kinds: map[string]int{}}
+	targets := map[FDTarget]int{}
+	for _, entry := range entries {
+		target, err := os.Readlink(filepath.Join(dir, entry.Name()))
+		if err != nil { // 读取过程中已关闭
+			continue
+		}
+
+		kind := fdKind(target)
+		s.Total++
+		s.Kinds[kind]++
+		targets[FDTarget{Kind: kind, Target: target}]++
+	}
+
+	for t, count := range targets {
+		t.Count = count
+		s.Top = append(s.Top, t)
+	}
+	sort.Slice(s.Top, func(i, j int) bool {
+		if s.Top[i].Count != s.Top[j].Count {
+			return s.Top[i].Count > s.Top[j].Count
+		}
+		return s.Top[i].Target < s.Top[j].Target
+	})
+	if len(s.Top) > topN {
+		s.Top = s.Top[:topN]
+	}
+
+	return s, nil
+}
+
+// fdKind 根据链接目标判断文件描述符类型, 例如 socket:[12345], pipe:[12345], anon_inode:[eventpoll], /path/to/file
+func fdKind(target string) string {
+	if strings.HasPrefix(target, "/") {
+		return "file"
+	}
+	if i := strings.Index(target, ":"); i > 0 {
+		return target[:i]
+	}
+	return "other"
+}
+
+func (w *Dog) statFD(p *process.Process, state *thresholdState) (debugMessage string) {
+	n, err := p.NumFDs()
+	if err != nil {
+		if w.Debug {
+			log.Printf("E! get fds %d error: %v", p.Pid, err)
+		}
+		return
+	}
+
+	// 仅在超标时汇总, 避免每次采样都遍历 /proc/<pid>/fd
+	if state.exceeds(uint64(n)) {
+		if s, err := ReadFDSummary(int(p.Pid), DefaultFDTopN); err == nil {
+			state.Detail = s
+		} else if w.Debug {
+			log.Printf("E! read fd summary %d error: %v", p.Pid, err)
+		}
+	}
+
+	state.setReached(w.Debug, uint64(n))
+	if w.Debug {
+		debugMessage = fmt.Sprintf("FDs: %d", n)
+	}
+	return
+}
+
+func (w *Dog) statThreads(p *process.Process, state *thresholdState) (debugMessage string) {
+	n, err := p.NumThreads()
+	if err != nil {
+		if w.Debug {
+			log.Printf("E! get threads %d error: %v", p.Pid, err)
+		}
+		return
+	}
+
+	state.setReached(w.Debug, uint64(n))
+	if w.Debug {
+		debugMessage = fmt.Sprintf("threads: %d", n)
+	}
+	return
+}
@@ -0,0 +1,145 @@
+package godog
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"runtime/metrics"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/process"
+)
+
+// GCSummary 两次采样之间的 GC 压力摘要
+type GCSummary struct {
+	// CPUPercent GC 占用 CPU 时间的百分比
+	CPUPercent float64 `json:"cpuPercent"`
+	// Cycles GC 次数
+	Cycles uint64 `json:"cycles"`
+	// Pauses GC STW 暂停次数
+	Pauses   uint64 `json:"pauses"`
+	PauseP50 string `json:"pauseP50"`
+	PauseP90 string `json:"pauseP90"`
+	PauseP99 string `json:"pauseP99"`
+	PauseMax string `json:"pauseMax"`
+
+	pauseP99 time.Duration
+}
+
+// gcSampler 读取 GC 相关 runtime/metrics, 并计算与上次采样的差值
+type gcSampler struct {
+	samples []metrics.Sample
+
+	prevGCCPU, prevTotalCPU float64
+	prevCycles              uint64
+	prevPauses              []uint64
+}
+
+func newGCSampler() *gcSampler {
+	return &gcSampler{
+		samples: []metrics.Sample{
+			{Name: "/cpu/classes/gc/total:cpu-seconds"},
+			{Name: "/cpu/classes/total:cpu-seconds"},
+			{Name: "/gc/cycles/total:gc-cycles"},
+			{Name: "/sched/pauses/total/gc:seconds"},
+		},
+	}
+}
+
+func (g *gcSampler) sample() (s GCSummary, err error) {
+	metrics.Read(g.samples)
+	for _, sample := range g.samples {
+		if sample.Value.Kind() == metrics.KindBad {
+			return s, fmt.Errorf("runtime metric %s is not supported", sample.Name)
+		}
+	}
+
+	gcCPU := g.samples[0].Value.Float64()
+	totalCPU := g.samples[1].Value.Float64()
+	cycles := g.samples[2].Value.Uint64()
+	hist := g.samples[3].Value.Float64Histogram()
+
+	if total := totalCPU - g.prevTotalCPU; total > 0 {
+		s.CPUPercent = (gcCPU - g.prevGCCPU) / total * 100
+	}
+	s.Cycles = cycles - g.prevCycles
+
+	counts := make([]uint64, len(hist.Counts))
+	for i, c := range hist.Counts {
+		counts[i] = c
+		if i < len(g.prevPauses) {
+			counts[i] -= g.prevPauses[i]
+		}
+		s.Pauses += counts[i]
+	}
+	s.pauseP99 = percentile(counts, hist.Buckets, s.Pauses, 0.99)
+	s.PauseP50 = percentile(counts, hist.Buckets, s.Pauses, 0.50).String()
+	s.PauseP90 = percentile(counts, hist.Buckets, s.Pauses, 0.90).String()
+	s.PauseP99 = s.pauseP99.String()
+	s.PauseMax = percentile(counts, hist.Buckets, s.Pauses, 1).String()
+
+	g.prevGCCPU, g.prevTotalCPU, g.prevCycles = gcCPU, totalCPU, cycles
+	g.prevPauses = append(g.prevPauses[:0], hist.Counts...)
+	return s, nil
+}
+
+// percentile 计算直方图的分位数, 返回所在桶的上界(上界为 +Inf 时返回下界)
+func percentile(counts []uint64, buckets []float64, total uint64, q float64) time.Duration {
+	if total == 0 {
+		return 0
+	}
+
+	rank := uint64(math.Ceil(float64(total) * q))
+	var sum uint64
+	for i, c := range counts {
+		if sum += c; sum >= rank {
+			bound := buckets[i+1]
+			if math.IsInf(bound, 1) {
+				bound = buckets[i]
+			}
+			return time.Duration(bound * float64(time.Second))
+		}
+	}
+
+	return 0
+}
+
+func (w *Dog) statGCCPU() statFn {
+	g := newGCSampler()
+	return func(_ *process.Process, state *thresholdState) (debugMessage string) {
+		s, err := g.sample()
+		if err != nil {
+			if w.Debug {
+				log.Printf("E! read gc metrics error: %v", err)
+			}
+			return
+		}
+
+		state.Detail = s
+		state.setReached(w.Debug, uint64(s.CPUPercent))
+		if w.Debug {
+			debugMessage = fmt.Sprintf("GC CPU: %f%%", s.CPUPercent)
+		}
+		return
+	}
+}
+
+func (w *Dog) statGCPause() statFn {
+	g := newGCSampler()
+	return func(_ *process.Process, state *thresholdState) (debugMessage string) {
+		s, err := g.sample()
+		if err != nil {
+			if w.Debug {
+				log.Printf("E! read gc metrics error: %v", err)
+			}
+			return
+		}
+
+		state.Detail = s
+		state.setReached(w.Debug, uint64(s.pauseP99))
+		if w.Debug {
+			debugMessage = fmt.Sprintf("GC pause p99: %s", s.PauseP99)
+		}
+		return
+	}
+}
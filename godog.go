@@ -4,7 +4,12 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"os"
+	"runtime"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/dustin/go-humanize"
 	"github.com/shirou/gopsutil/v4/process"
@@ -14,50 +19,171 @@ type Dog struct {
 	*Config
 
 	states []*thresholdState
+
+	ctx       context.Context
+	cancel    context.CancelFunc
+	hooks     []ShutdownHook
+	hooksLock sync.Mutex
+
+	runLock sync.Mutex
+	stop    context.CancelFunc
+	done    chan struct{}
+	running atomic.Int32
+
+	// sample 本次采样中的状态, 只在监控协程中访问
+	sample   State
+	tree     processTree
+	snapshot atomic.Pointer[State]
+	subs     map[chan Event]struct{}
+	subsLock sync.Mutex
+
+	counters counters
+
+	// mu 保护运行时可修改的设置和阈值状态
+	mu     sync.Mutex
+	reset  chan struct{}
+	paused atomic.Bool
 }
 
 func New(options ...ConfigFn) *Dog {
 	d := &Dog{
-		Config: createConfig(options),
+		reset: make(chan struct{}, 1),
+	}
+	d.ctx, d.cancel = context.WithCancel(context.Background())
+	c, err := createConfig(options)
+	if err != nil {
+		log.Printf("W! godog invalid config, use defaults for invalid values: %v", err)
 	}
+	d.setConfig(c)
+	return d
+}
 
-	if d.RSSThreshold > 0 {
-		d.states = append(d.states, newThresholdState(RSS, d.RSSThreshold, d.statRSS, d.Dir, d.Pid))
+// setConfig 设置配置并创建阈值状态, 监控开始后需要持有 mu 调用
+func (w *Dog) setConfig(c *Config) {
+	if c.Action == nil {
+		c.Action = w.GracefulExitAction()
 	}
-	if d.CPUPercentThreshold > 0 {
-		d.states = append(d.states, newThresholdState(CPU, d.CPUPercentThreshold, d.statCPU, d.Dir, d.Pid))
+	w.Config = c
+	w.states = nil
+
+	w.addState(RSS, w.RSSThreshold, w.statRSS)
+	w.addState(CPU, w.CPUPercentThreshold, w.statCPU)
+	w.addState(FD, w.FDThreshold, w.statFD)
+	w.addState(Threads, w.ThreadsThreshold, w.statThreads)
+	w.addState(CgroupMemory, w.CgroupMemoryThreshold, w.statCgroupMemory())
+	w.addState(Throttled, w.ThrottledThreshold, w.statThrottled(false))
+	w.addState(ThrottledTime, uint64(w.ThrottledTimeThreshold), w.statThrottled(true))
+	// 以下指标只能在进程内部获取
+	if w.Pid == os.Getpid() {
+		w.addState(Goroutines, w.GoroutineThreshold, w.statGoroutines)
+		w.addState(HeapLive, w.HeapLiveThreshold, w.statRuntimeMetric(HeapLive))
+		w.addState(HeapObjects, w.HeapObjectsThreshold, w.statRuntimeMetric(HeapObjects))
+		w.addState(Stacks, w.StacksThreshold, w.statRuntimeMetric(Stacks))
+		w.addState(HeapFree, w.HeapFreeThreshold, w.statRuntimeMetric(HeapFree))
+		w.addState(GCCPU, w.GCCPUThreshold, w.statGCCPU())
+		w.addState(GCPause, uint64(w.GCPauseThreshold), w.statGCPause())
 	}
 
-	return d
+	if w.TrendHorizon > 0 {
+		for _, state := range w.states {
+			if state.Severity != Critical || state.Threshold == 0 {
+				continue
+			}
+			switch state.Type {
+			case RSS:
+				w.states = append(w.states, newTrendState(RSSTrend, state, w.TrendWindow, w.TrendHorizon))
+			case HeapLive:
+				w.states = append(w.states, newTrendState(HeapLiveTrend, state, w.TrendWindow, w.TrendHorizon))
+			}
+		}
+	}
+
+	for _, state := range w.states {
+		if p, ok := w.Policies[state.Type]; ok {
+			state.applyPolicy(p, w.Times)
+		}
+		state.cooldown = newCooldown(w.Config)
+	}
 }
 
-type State struct {
-	RSS        uint64
-	VMS        uint64
-	CPUPercent float64
+// addState 添加阈值状态, 上限为 0 且没有设置警告级别时不检查,
+// 只设置警告级别时, 严重级别的状态只负责采样
+func (w *Dog) addState(typ ThresholdType, threshold uint64, fn statFn) {
+	warn := w.WarnThresholds[typ]
+	if threshold == 0 && warn == 0 {
+		return
+	}
+
+	state := newThresholdState(typ, threshold, fn, w.Dir, w.Pid)
+	w.states = append(w.states, state)
+	if warn > 0 {
+		warnState := newThresholdState(typ, warn, nil, w.Dir, w.Pid)
+		warnState.Severity = Warn
+		state.followers = append(state.followers, warnState)
+		w.states = append(w.states, warnState)
+	}
 }
 
+// Watch 在当前协程中监控, 直到 ctx 取消
 func (w *Dog) Watch(ctx context.Context) error {
-	pid := w.Pid
-
-	p, err := process.NewProcess(int32(pid))
+	p, err := w.newProcess()
 	if err != nil {
-		return fmt.Errorf("get process %d: %w", pid, err)
+		return err
 	}
 
-	return Tick(ctx, w.Interval, w.Jitter, func() error {
+	w.running.Add(1)
+	defer w.running.Add(-1)
+	return w.watch(ctx, p)
+}
+
+func (w *Dog) watch(ctx context.Context, p *process.Process) error {
+	defer w.closeProfiles()
+
+	return tick(ctx, w.intervals, w.reset, func() error {
+		if p = w.target(p); p == nil {
+			return nil
+		}
+
+		w.mu.Lock()
+		if w.paused.Load() {
+			w.mu.Unlock()
+			return nil
+		}
+		w.sample = State{Time: time.Now()}
 		w.stat(p)
-		if reasons, yes := w.reachTimes(); yes {
-			if w.Debug {
+		state := w.takeSnapshot()
+		reasons, yes := w.reachTimes()
+		// 动作在锁外执行, 配置可能被 Reload 替换, 先取出当前配置
+		c := w.Config
+		if yes {
+			if c.Debug {
 				log.Printf("godo reach times: %v", reasons)
 			}
-
-			w.Action.DoAction(w.Dir, w.Debug, reasons)
+			w.publish(Event{Type: EventReached, State: state, Reasons: reasons})
+		}
+		w.mu.Unlock()
+
+		if yes {
+			var warns, criticals []ReasonItem
+			for _, r := range reasons {
+				if r.Severity == Warn {
+					warns = append(warns, r)
+				} else {
+					criticals = append(criticals, r)
+				}
+			}
+			if len(warns) > 0 {
+				w.counters.addReasons(Warn, warns)
+				c.WarnAction.DoAction(c.Dir, c.Debug, warns)
+			}
+			if len(criticals) > 0 {
+				w.counters.addReasons(Critical, criticals)
+				c.Action.DoAction(c.Dir, c.Debug, criticals)
+			}
 		}
 
 		return nil
 	})
-
 }
 
 type statFn func(p *process.Process, state *thresholdState) (debugMessage string)
@@ -65,6 +191,9 @@ type statFn func(p *process.Process, state *thresholdState) (debugMessage string
 func (w *Dog) stat(p *process.Process) {
 	var debugMessages []string
 	for _, state := range w.states {
+		if state.statFn == nil { // 跟随其它状态采样
+			continue
+		}
 		if msg := state.statFn(p, state); msg != "" {
 			debugMessages = append(debugMessages, msg)
 		}
@@ -79,6 +208,11 @@ func (w *Dog) statRSS(p *process.Process, state *thresholdState) (debugMessage s
 	// 获取内存信息
 	if memInfo, err := p.MemoryInfo(); err == nil {
 		rss := memInfo.RSS // 常驻集大小，即实际使用的物理内存
+		if w.ProcessTree {
+			d, sum := treeDetail(RSS, rss, w.descendants(p))
+			state.Detail, rss = d, rss+uint64(sum)
+		}
+		w.sample.RSS, w.sample.VMS = rss, memInfo.VMS
 		state.setReached(w.Debug, rss)
 
 		if w.Debug {
@@ -94,6 +228,11 @@ func (w *Dog) statRSS(p *process.Process, state *thresholdState) (debugMessage s
 func (w *Dog) statCPU(p *process.Process, state *thresholdState) (debugMessage string) {
 	// 获取CPU使用情况
 	if cpuPercent, err := p.CPUPercent(); err == nil {
+		if w.ProcessTree {
+			d, sum := treeDetail(CPU, uint64(cpuPercent), w.descendants(p))
+			state.Detail, cpuPercent = d, cpuPercent+sum
+		}
+		w.sample.CPUPercent = cpuPercent
 		state.setReached(w.Debug, uint64(cpuPercent))
 		if w.Debug {
 			debugMessage = fmt.Sprintf("CPU: %f", cpuPercent)
@@ -105,23 +244,52 @@ func (w *Dog) statCPU(p *process.Process, state *thresholdState) (debugMessage s
 	return
 }
 
+func (w *Dog) statGoroutines(_ *process.Process, state *thresholdState) (debugMessage string) {
+	n := runtime.NumGoroutine()
+	state.setReached(w.Debug, uint64(n))
+	if w.Debug {
+		debugMessage = fmt.Sprintf("goroutines: %d", n)
+	}
+
+	return
+}
+
 type ReasonItem struct {
 	Type      ThresholdType `json:"type"`
+	Severity  Severity      `json:"severity"`
 	Reason    string        `json:"reason"`
 	Values    []uint64      `json:"values"`
 	Threshold any           `json:"threshold"`
 	Profile   string        `json:"profile"`
+	// Detail 触发时的附加信息, 例如 GC 暂停直方图摘要
+	Detail any `json:"detail,omitempty"`
+	// Suppressed 上次触发以来, 冷却期间被抑制的触发次数
+	Suppressed int `json:"suppressed,omitempty"`
+	// Pid 被监控的进程号
+	Pid int `json:"pid,omitempty"`
 }
 
 func (w *Dog) reachTimes() (reasons []ReasonItem, reached bool) {
 	for _, state := range w.states {
-		if r := state.reached(w.Times, w.Debug); r.Reached {
+		times := w.timesOf(state)
+		if r := state.reached(times, w.Debug); r.Reached {
+			reason := state.reason(times)
+			if d, ok := r.Detail.(*ProcessTreeDetail); ok && len(d.Top) > 0 {
+				reason += ", 占用最多的子孙进程: " + d.String()
+			}
+			if r.Suppressed > 0 {
+				reason += fmt.Sprintf(", 冷却期间抑制 %d 次", r.Suppressed)
+			}
 			reasons = append(reasons, ReasonItem{
-				Type:      state.Type,
-				Reason:    fmt.Sprintf("连续 %d 次超标", w.Times),
-				Values:    r.Values,
-				Threshold: state.Threshold,
-				Profile:   r.Profile,
+				Type:       state.Type,
+				Severity:   state.Severity,
+				Reason:     reason,
+				Values:     r.Values,
+				Threshold:  state.Threshold,
+				Profile:    r.Profile,
+				Detail:     r.Detail,
+				Suppressed: r.Suppressed,
+				Pid:        state.Pid,
 			})
 			reached = true
 		}
@@ -130,27 +298,99 @@ func (w *Dog) reachTimes() (reasons []ReasonItem, reached bool) {
 	return reasons, reached
 }
 
+// timesOf 状态触发需要的超标次数
+func (w *Dog) timesOf(state *thresholdState) int {
+	if state.Times > 0 {
+		return state.Times
+	}
+	return w.Times
+}
+
 type ThresholdType string
 
 const (
-	RSS ThresholdType = "RSS"
-	CPU ThresholdType = "CPU"
+	RSS        ThresholdType = "RSS"
+	CPU        ThresholdType = "CPU"
+	Goroutines ThresholdType = "Goroutines"
+	FD         ThresholdType = "FD"
+	Threads    ThresholdType = "Threads"
+
+	// HeapLive 上次 GC 标记的存活堆对象字节数
+	HeapLive ThresholdType = "HeapLive"
+	// HeapObjects 堆对象个数
+	HeapObjects ThresholdType = "HeapObjects"
+	// Stacks 协程栈占用字节数
+	Stacks ThresholdType = "Stacks"
+	// HeapFree 已释放但尚未归还操作系统的堆字节数
+	HeapFree ThresholdType = "HeapFree"
+	// GCCPU 采样间隔内 GC 占用 CPU 时间百分比
+	GCCPU ThresholdType = "GCCPU"
+	// GCPause 采样间隔内 GC 暂停时间 p99 (纳秒)
+	GCPause ThresholdType = "GCPause"
+	// CgroupMemory cgroup 内存用量(包含 page cache 和子进程)
+	CgroupMemory ThresholdType = "CgroupMemory"
+	// Throttled 采样间隔内 CFS 限流周期数
+	Throttled ThresholdType = "Throttled"
+	// ThrottledTime 采样间隔内 CFS 限流时间(纳秒)
+	ThrottledTime ThresholdType = "ThrottledTime"
+	// RSSTrend RSS 增长趋势预计在 Config.TrendHorizon 内超标
+	RSSTrend ThresholdType = "RSSTrend"
+	// HeapLiveTrend 存活堆增长趋势预计在 Config.TrendHorizon 内超标
+	HeapLiveTrend ThresholdType = "HeapLiveTrend"
+)
+
+// Severity 超标的严重级别
+type Severity string
+
+const (
+	// Warn 警告级别, 触发 Config.WarnAction, 通常不退出
+	Warn Severity = "warn"
+	// Critical 严重级别, 触发 Config.Action
+	Critical Severity = "critical"
 )
 
 type thresholdState struct {
 	Type      ThresholdType
+	Severity  Severity
 	Threshold uint64
 	Values    []uint64
+	// Detail 最近一次采样的附加信息
+	Detail any
+	// last 最近一次采样值, sampled 是否已经采样
+	last    uint64
+	sampled bool
+	// Times 连续多少次, 0 时使用 Config.Times
+	Times int
+	// Window 大于 Times 时, 最近 Window 次采样中有 Times 次超标即触发
+	Window int
+	// Clear 滞回下限, 大于 0 时, 超标后采样值回落到 Clear 及以下才算恢复
+	Clear uint64
 
 	statFn
+	// samples 最近 Window 次采样
+	samples []windowSample
+	// breaching 是否处于超标状态(用于滞回判定)
+	breaching bool
+	// cooldown 不为 nil 时控制重复触发的冷却
+	cooldown *cooldown
+	// followers 跟随本状态采样的其它状态, 例如趋势检测
+	followers []*thresholdState
+	// trend 不为 nil 时按增长趋势判断是否超标
+	trend   *trendWindow
 	profile Profile
 	Dir     string
 	Pid     int
 }
 
+type windowSample struct {
+	value uint64
+	over  bool
+}
+
 func newThresholdState(typ ThresholdType, threshold uint64, fn statFn, dir string, pid int) *thresholdState {
 	return &thresholdState{
 		Type:      typ,
+		Severity:  Critical,
 		Threshold: threshold,
 		statFn:    fn,
 		Dir:       dir,
@@ -159,9 +399,11 @@ func newThresholdState(typ ThresholdType, threshold uint64, fn statFn, dir strin
 }
 
 type reachResult struct {
-	Profile string
-	Values  []uint64
-	Reached bool
+	Profile    string
+	Values     []uint64
+	Detail     any
+	Suppressed int
+	Reached    bool
 }
 
 func (t *thresholdState) reached(maxTimes int, debug bool) (r reachResult) {
@@ -171,10 +413,31 @@ func (t *thresholdState) reached(maxTimes int, debug bool) (r reachResult) {
 
 	if r.Reached = len(t.Values) >= maxTimes; r.Reached {
 		r.Values = t.Values
+		r.Detail = t.Detail
 		t.Values = nil
+		t.samples = nil
+		if t.trend != nil {
+			t.trend.reset()
+		}
+
+		now := time.Now()
+		if t.cooldown.suppressing(now) {
+			t.cooldown.suppress()
+			t.closeProfile(debug)
+			if debug {
+				log.Printf("%s reach times suppressed in cooldown", t.Type)
+			}
+			return reachResult{}
+		}
+		r.Suppressed = t.cooldown.fire(now)
 
+		// 性能分析只能采集看门狗所在的进程
+		self := t.Pid == os.Getpid()
 		switch t.Type {
-		case RSS:
+		case RSS, HeapLive, HeapObjects, HeapFree, GCCPU, GCPause, CgroupMemory, RSSTrend, HeapLiveTrend:
+			if !self {
+				break
+			}
 			if p, err := CreateMemProfile(t.Dir, t.Pid); err != nil {
 				if debug {
 					log.Printf("E! create mem profile error: %v", err)
@@ -182,6 +445,17 @@ func (t *thresholdState) reached(maxTimes int, debug bool) (r reachResult) {
 			} else {
 				r.Profile = p.ProfileName()
 			}
+		case Goroutines, Stacks, Threads:
+			if !self {
+				break
+			}
+			if p, err := CreateGoroutineProfile(t.Dir, t.Pid); err != nil {
+				if debug {
+					log.Printf("E! create goroutine profile error: %v", err)
+				}
+			} else {
+				r.Profile = p.ProfileName()
+			}
 		default:
 			if t.profile != nil {
 				if err := t.profile.Close(); err != nil && debug {
@@ -197,32 +471,148 @@ func (t *thresholdState) reached(maxTimes int, debug bool) (r reachResult) {
 	return
 }
 
-func (t *thresholdState) setReached(debug bool, value uint64) {
-	if reached := value > t.Threshold; reached {
-		if t.profile == nil {
-			switch t.Type {
-			case CPU:
-				var err error
-				t.profile, err = CreateCPUProfile(t.Dir, t.Pid)
-				if err != nil {
-					if debug {
-						log.Printf("E! create cpu profile error: %v", err)
-					}
-					t.profile = &noopProfile{}
-				}
-			default:
-				t.profile = &noopProfile{}
-			}
+// applyPolicy 应用判定策略, defaultTimes 为 Config.Times
+func (t *thresholdState) applyPolicy(p Policy, defaultTimes int) {
+	if p.Times > 0 {
+		t.Times = p.Times
+	} else if t.Times == 0 {
+		t.Times = defaultTimes
+	}
+	if p.Window > t.Times {
+		t.Window = p.Window
+	}
+	if p.Clear > 0 && p.Clear < t.Threshold {
+		t.Clear = p.Clear
+	}
+}
+
+func (t *thresholdState) reason(times int) string {
+	var reason string
+	switch {
+	case t.trend != nil:
+		reason = t.trend.reason()
+	case t.Window > 0:
+		reason = fmt.Sprintf("最近 %d 次采样中 %d 次超标", t.Window, times)
+	default:
+		reason = fmt.Sprintf("连续 %d 次超标", times)
+	}
+
+	if t.Clear > 0 {
+		reason += fmt.Sprintf(", 超标后回落到 %d 及以下才恢复", t.Clear)
+	}
+	return reason
+}
+
+// exceeds 采样值是否超过本状态或警告级别的上限, 用于决定是否收集代价较高的附加信息
+func (t *thresholdState) exceeds(value uint64) bool {
+	if t.Threshold > 0 && value > t.Threshold {
+		return true
+	}
+	for _, f := range t.followers {
+		if f.trend == nil && f.exceeds(value) {
+			return true
 		}
+	}
+	return false
+}
+
+// over 判断采样值是否超标, 设置了滞回下限 Clear 时, 处于超标状态的采样值高于 Clear 即算超标
+func (t *thresholdState) over(value uint64) bool {
+	if t.trend != nil {
+		return t.trend.reached(value, t.Threshold, &t.Detail)
+	}
+
+	// 上限为 0 时只采样不判定
+	if t.Threshold == 0 {
+		return false
+	}
+
+	over := value > t.Threshold || t.breaching && value > t.Clear
+	if t.Clear > 0 {
+		t.breaching = over
+	}
+	return over
+}
+
+func (t *thresholdState) setReached(debug bool, value uint64) {
+	for _, f := range t.followers {
+		f.Detail = t.Detail
+		f.setReached(debug, value)
+	}
+	t.last, t.sampled = value, true
+
+	reached := t.over(value)
+	if t.Window > 0 {
+		t.setWindowReached(debug, value, reached)
+		return
+	}
+
+	if reached {
+		t.startProfile(debug)
 		t.Values = append(t.Values, value)
 	} else {
-		if t.profile != nil {
-			if err := t.profile.Close(); err != nil && debug {
-				log.Printf("E! profile close error: %v", err)
-			}
-		}
+		t.closeProfile(debug)
+		t.cooldown.reset()
 		if len(t.Values) > 0 {
 			t.Values = t.Values[:0]
 		}
 	}
 }
+
+// setWindowReached 滑动窗口判定, Values 为窗口内超标的采样值
+func (t *thresholdState) setWindowReached(debug bool, value uint64, reached bool) {
+	if reached {
+		t.startProfile(debug)
+	}
+
+	t.samples = append(t.samples, windowSample{value: value, over: reached})
+	if n := len(t.samples); n > t.Window {
+		t.samples = t.samples[n-t.Window:]
+	}
+
+	t.Values = t.Values[:0]
+	for _, s := range t.samples {
+		if s.over {
+			t.Values = append(t.Values, s.value)
+		}
+	}
+	if len(t.Values) == 0 {
+		t.closeProfile(debug)
+		t.cooldown.reset()
+	}
+}
+
+func (t *thresholdState) startProfile(debug bool) {
+	// 冷却期间不启动性能分析, 避免覆盖上次触发时生成的文件
+	if t.profile != nil || t.cooldown.suppressing(time.Now()) {
+		return
+	}
+
+	switch t.Type {
+	case CPU:
+		// CPU 性能分析同一时间只能有一个, 留给严重级别, 且只能采集看门狗所在的进程
+		if t.Severity != Critical || t.Pid != os.Getpid() {
+			t.profile = &noopProfile{}
+			break
+		}
+		var err error
+		t.profile, err = CreateCPUProfile(t.Dir, t.Pid)
+		if err != nil {
+			if debug {
+				log.Printf("E! create cpu profile error: %v", err)
+			}
+			t.profile = &noopProfile{}
+		}
+	default:
+		t.profile = &noopProfile{}
+	}
+}
+
+func (t *thresholdState) closeProfile(debug bool) {
+	if t.profile != nil {
+		if err := t.profile.Close(); err != nil && debug {
+			log.Printf("E! profile close error: %v", err)
+		}
+		t.profile = nil
+	}
+}
@@ -0,0 +1,65 @@
+package godog
+
+import (
+	"slices"
+	"testing"
+)
+
+// feedState 依次采样 values, 返回每次采样后是否触发
+func feedState(t *thresholdState, values []uint64) []bool {
+	var got []bool
+	for _, v := range values {
+		t.setReached(false, v)
+		got = append(got, t.reached(t.Times, false).Reached)
+	}
+	return got
+}
+
+func TestThresholdStatePolicy(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy Policy
+		values []uint64
+		want   []bool
+	}{
+		{"consecutive", Policy{Times: 3}, []uint64{11, 11, 5, 11, 11, 11}, []bool{false, false, false, false, false, true}},
+		{"window 3/5", Policy{Times: 3, Window: 5}, []uint64{11, 5, 11, 5, 11}, []bool{false, false, false, false, true}},
+		{"window slides out old samples", Policy{Times: 3, Window: 5},
+			[]uint64{11, 11, 5, 5, 5, 11, 5, 11}, []bool{false, false, false, false, false, false, false, false}},
+		{"window resets after reached", Policy{Times: 2, Window: 3},
+			[]uint64{11, 11, 11, 5, 11}, []bool{false, true, false, false, true}},
+		{"window not larger than times is ignored", Policy{Times: 2, Window: 2},
+			[]uint64{11, 5, 11}, []bool{false, false, false}},
+		{"clear keeps breaching above clear", Policy{Times: 2, Clear: 5},
+			[]uint64{11, 8, 8, 4, 8, 8}, []bool{false, true, false, false, false, false}},
+		{"clear not below threshold is ignored", Policy{Times: 2, Clear: 20},
+			[]uint64{11, 8, 11}, []bool{false, false, false}},
+		{"clear with window", Policy{Times: 2, Window: 4, Clear: 5},
+			[]uint64{11, 4, 8, 11}, []bool{false, false, false, true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := newThresholdState(FD, 10, nil, t.TempDir(), 0)
+			state.applyPolicy(tt.policy, DefaultTimes)
+			if got := feedState(state, tt.values); !slices.Equal(got, tt.want) {
+				t.Errorf("reached = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestThresholdStateWindowValues(t *testing.T) {
+	state := newThresholdState(FD, 10, nil, t.TempDir(), 0)
+	state.applyPolicy(Policy{Times: 3, Window: 4}, DefaultTimes)
+	feedState(state, []uint64{12, 5, 13, 6})
+	if want := []uint64{12, 13}; !slices.Equal(state.Values, want) {
+		t.Fatalf("Values = %v, want %v", state.Values, want)
+	}
+
+	state.setReached(false, 14)
+	r := state.reached(state.Times, false)
+	if want := []uint64{13, 14}; r.Reached || !slices.Equal(state.Values, want) {
+		t.Fatalf("reached = %t, Values = %v, want false, %v", r.Reached, state.Values, want)
+	}
+}
@@ -0,0 +1,99 @@
+package godog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/shirou/gopsutil/v4/process"
+)
+
+// ErrRunning 看门狗已经通过 Start 启动
+var ErrRunning = errors.New("godog: already running")
+
+// Start 在后台开始监控, 重复调用返回 ErrRunning, 使用 Stop 停止
+func (w *Dog) Start() error {
+	w.runLock.Lock()
+	defer w.runLock.Unlock()
+
+	if w.done != nil {
+		return ErrRunning
+	}
+
+	p, err := w.newProcess()
+	if err != nil {
+		return err
+	}
+
+	debug := w.config().Debug
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	w.stop, w.done = cancel, done
+	w.running.Add(1)
+	go func() {
+		defer close(done)
+		defer w.running.Add(-1)
+		if err := w.watch(ctx, p); err != nil && !errors.Is(err, context.Canceled) && debug {
+			log.Printf("E! watch error: %v", err)
+		}
+	}()
+
+	return nil
+}
+
+// Stop 停止 Start 启动的监控, 等待监控退出并关闭(写入)未完成的性能分析文件,
+// ctx 到期时不再等待, 返回 ctx 的错误
+func (w *Dog) Stop(ctx context.Context) error {
+	w.runLock.Lock()
+	stop, done := w.stop, w.done
+	w.runLock.Unlock()
+
+	if done == nil {
+		return nil
+	}
+
+	stop()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	w.runLock.Lock()
+	if w.done == done {
+		w.stop, w.done = nil, nil
+	}
+	w.runLock.Unlock()
+	return nil
+}
+
+// Running 是否正在监控(通过 Start 或 Watch)
+func (w *Dog) Running() bool {
+	return w.running.Load() > 0
+}
+
+// newProcess 创建要监控的进程, 设置了 PidResolver 时在监控过程中查找, 返回 nil
+func (w *Dog) newProcess() (*process.Process, error) {
+	c := w.config()
+	if c.PidResolver != nil {
+		return nil, nil
+	}
+
+	pid := c.Pid
+	p, err := process.NewProcess(int32(pid))
+	if err != nil {
+		return nil, fmt.Errorf("get process %d: %w", pid, err)
+	}
+	return p, nil
+}
+
+// closeProfiles 监控退出时关闭所有未完成的性能分析
+func (w *Dog) closeProfiles() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, state := range w.states {
+		state.closeProfile(w.Debug)
+	}
+}
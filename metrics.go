@@ -0,0 +1,54 @@
+package godog
+
+import (
+	"fmt"
+	"log"
+	"runtime/metrics"
+
+	"github.com/dustin/go-humanize"
+	"github.com/shirou/gopsutil/v4/process"
+)
+
+// runtimeMetricNames 阈值类型对应的 runtime/metrics 指标名称
+var runtimeMetricNames = map[ThresholdType]string{
+	HeapLive:    "/gc/heap/live:bytes",
+	HeapObjects: "/gc/heap/objects:objects",
+	Stacks:      "/memory/classes/heap/stacks:bytes",
+	HeapFree:    "/memory/classes/heap/free:bytes",
+}
+
+// readRuntimeMetric 读取单个 runtime/metrics 整数指标
+func readRuntimeMetric(name string) (uint64, error) {
+	samples := []metrics.Sample{{Name: name}}
+	metrics.Read(samples)
+
+	if v := samples[0].Value; v.Kind() == metrics.KindUint64 {
+		return v.Uint64(), nil
+	}
+
+	return 0, fmt.Errorf("runtime metric %s is not supported", name)
+}
+
+// statRuntimeMetric 创建读取 runtime/metrics 指标的 statFn
+func (w *Dog) statRuntimeMetric(typ ThresholdType) statFn {
+	name := runtimeMetricNames[typ]
+	return func(_ *process.Process, state *thresholdState) (debugMessage string) {
+		val, err := readRuntimeMetric(name)
+		if err != nil {
+			if w.Debug {
+				log.Printf("E! read %s error: %v", typ, err)
+			}
+			return
+		}
+
+		state.setReached(w.Debug, val)
+		if w.Debug {
+			if typ == HeapObjects {
+				debugMessage = fmt.Sprintf("%s: %d", typ, val)
+			} else {
+				debugMessage = fmt.Sprintf("%s: %s", typ, humanize.IBytes(val))
+			}
+		}
+		return
+	}
+}
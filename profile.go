@@ -31,16 +31,39 @@ func CreateMemProfile(dir string, pid int) (Profile, error) {
 	return &profile{Name: name}, nil
 }
 
-// CreateCPUProfile 创建 CPU 性能分析文件
-func CreateCPUProfile(dir string, pid int) (Profile, error) {
-	name := filepath.Join(dir, fmt.Sprintf("Dog.cpu.%d.prof", pid))
+// CreateGoroutineProfile 创建协程性能分析文件
+func CreateGoroutineProfile(dir string, pid int) (Profile, error) {
+	name := filepath.Join(dir, fmt.Sprintf("Dog.goroutine.%d.prof", pid))
 	f, err := os.Create(name)
 	if err != nil {
 		return nil, fmt.Errorf("create profile file %s: %w", name, err)
 	}
+	defer f.Close()
+
+	if err := pprof.Lookup("goroutine").WriteTo(f, 0); err != nil {
+		return nil, fmt.Errorf("write goroutine profile: %w", err)
+	}
+
+	return &profile{Name: name}, nil
+}
+
+// CreateCPUProfile 创建 CPU 性能分析文件 Dog.cpu.<pid>.prof
+func CreateCPUProfile(dir string, pid int) (Profile, error) {
+	return createCPUProfile(filepath.Join(dir, fmt.Sprintf("Dog.cpu.%d.prof", pid)))
+}
+
+// createCPUProfile 启动 CPU 性能分析, 采集期间写入同目录的临时文件, Close 时重命名为 name,
+// 已有 CPU 性能分析在进行时删除临时文件并返回错误, 不影响正在写入的文件
+func createCPUProfile(name string) (Profile, error) {
+	f, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("create profile file %s: %w", name, err)
+	}
 
 	// 启动 CPU 性能分析
 	if err := pprof.StartCPUProfile(f); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
 		return nil, fmt.Errorf("start CPU profile: %w", err)
 	}
 
@@ -63,6 +86,9 @@ func (c *profile) Close() error {
 		if err := c.File.Close(); err != nil {
 			return fmt.Errorf("close CPU profile: %w", err)
 		}
+		if err := os.Rename(c.File.Name(), c.Name); err != nil {
+			return fmt.Errorf("rename CPU profile: %w", err)
+		}
 	}
 
 	return nil
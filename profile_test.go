@@ -0,0 +1,44 @@
+package godog
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCreateCPUProfileConflict(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "Dog.cpu.1.prof")
+	if err := os.WriteFile(name, []byte("previous"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := CreateCPUProfile(dir, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 已有 CPU 性能分析在进行, 不能截断或留下文件
+	if _, err := CreateCPUProfile(dir, 1); err == nil {
+		t.Fatal("second CreateCPUProfile want error")
+	}
+	if _, err := createCPUProfile(filepath.Join(dir, "Dog.cpu.admin.1.prof")); err == nil {
+		t.Fatal("admin createCPUProfile want error")
+	}
+	if data, _ := os.ReadFile(name); string(data) != "previous" {
+		t.Fatalf("%s = %q, want untouched while profiling", name, data)
+	}
+
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "Dog.cpu.1.prof" {
+		t.Fatalf("files = %v, want only Dog.cpu.1.prof", entries)
+	}
+	if data, _ := os.ReadFile(name); string(data) == "previous" || len(data) == 0 {
+		t.Fatalf("%s not replaced by the new profile", name)
+	}
+}
@@ -0,0 +1,158 @@
+package godog
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// counters 累计计数, 用于输出 Prometheus 指标
+type counters struct {
+	sync.Mutex
+	// actions 各级别动作执行次数
+	actions map[Severity]uint64
+	// profiles 各阈值类型生成的性能分析文件个数
+	profiles map[string]uint64
+}
+
+// addReasons 记录一次动作执行, 以及触发原因中生成的性能分析文件
+func (c *counters) addReasons(severity Severity, reasons []ReasonItem) {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.actions == nil {
+		c.actions = map[Severity]uint64{}
+	}
+	c.actions[severity]++
+	for _, r := range reasons {
+		if r.Profile != "" {
+			c.addProfileLocked(string(r.Type))
+		}
+	}
+}
+
+// addProfile 记录一次生成的性能分析文件
+func (c *counters) addProfile(source string) {
+	c.Lock()
+	defer c.Unlock()
+	c.addProfileLocked(source)
+}
+
+func (c *counters) addProfileLocked(source string) {
+	if c.profiles == nil {
+		c.profiles = map[string]uint64{}
+	}
+	c.profiles[source]++
+}
+
+// MetricsHandler 以 Prometheus 文本格式输出看门狗的采样值, 阈值, 当前连续超标次数,
+// 以及动作执行次数和性能分析文件个数
+func MetricsHandler(dog *Dog) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		rw.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		dog.WriteMetrics(rw)
+	})
+}
+
+// WriteMetrics 以 Prometheus 文本格式写出指标
+func (w *Dog) WriteMetrics(out io.Writer) {
+	s := w.Snapshot()
+	m := &metricsWriter{w: out}
+
+	running := 0.0
+	if w.Running() {
+		running = 1
+	}
+	m.gauge("godog_running", "Whether the watchdog is watching (1) or not (0).", running)
+	if !s.Time.IsZero() {
+		m.gauge("godog_last_sample_timestamp_seconds", "Unix time of the last sample.", float64(s.Time.UnixNano())/1e9)
+		m.gauge("godog_rss_bytes", "Resident set size of the watched process.", float64(s.RSS))
+		m.gauge("godog_vms_bytes", "Virtual memory size of the watched process.", float64(s.VMS))
+		m.gauge("godog_cpu_percent", "CPU percent of the watched process.", s.CPUPercent)
+	}
+
+	m.family("godog_metric_value", "gauge", "Last sampled value of each watched metric.")
+	for _, ms := range s.Metrics {
+		m.sample("godog_metric_value", float64(ms.Value), "type", string(ms.Type), "severity", string(ms.Severity))
+	}
+	m.family("godog_threshold", "gauge", "Configured threshold of each watched metric.")
+	for _, ms := range s.Metrics {
+		m.sample("godog_threshold", float64(ms.Threshold), "type", string(ms.Type), "severity", string(ms.Severity))
+	}
+	m.family("godog_breach_streak", "gauge", "Current breach count of each watched metric.")
+	for _, ms := range s.Metrics {
+		m.sample("godog_breach_streak", float64(ms.Streak), "type", string(ms.Type), "severity", string(ms.Severity))
+	}
+	m.family("godog_breach_times", "gauge", "Breach count that fires the action of each watched metric.")
+	for _, ms := range s.Metrics {
+		m.sample("godog_breach_times", float64(ms.Times), "type", string(ms.Type), "severity", string(ms.Severity))
+	}
+
+	w.counters.Lock()
+	actions := sortedCounters(w.counters.actions)
+	profiles := sortedCounters(w.counters.profiles)
+	w.counters.Unlock()
+
+	m.family("godog_actions_fired_total", "counter", "Number of actions fired.")
+	for _, c := range actions {
+		m.sample("godog_actions_fired_total", float64(c.value), "severity", c.key)
+	}
+	m.family("godog_profiles_written_total", "counter", "Number of profiles written.")
+	for _, c := range profiles {
+		m.sample("godog_profiles_written_total", float64(c.value), "type", c.key)
+	}
+}
+
+type counterValue struct {
+	key   string
+	value uint64
+}
+
+func sortedCounters[K ~string](m map[K]uint64) []counterValue {
+	values := make([]counterValue, 0, len(m))
+	for k, v := range m {
+		values = append(values, counterValue{key: string(k), value: v})
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i].key < values[j].key })
+	return values
+}
+
+// metricsWriter 写出 Prometheus 文本格式
+type metricsWriter struct {
+	w io.Writer
+}
+
+func (m *metricsWriter) family(name, typ, help string) {
+	fmt.Fprintf(m.w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func (m *metricsWriter) gauge(name, help string, value float64) {
+	m.family(name, "gauge", help)
+	m.sample(name, value)
+}
+
+// sample 写出一个采样, labels 为标签名和标签值交替排列
+func (m *metricsWriter) sample(name string, value float64, labels ...string) {
+	var b strings.Builder
+	b.WriteString(name)
+	for i := 0; i+1 < len(labels); i += 2 {
+		if i == 0 {
+			b.WriteByte('{')
+		} else {
+			b.WriteByte(',')
+		}
+		b.WriteString(labels[i])
+		b.WriteString(`="`)
+		b.WriteString(labelEscaper.Replace(labels[i+1]))
+		b.WriteByte('"')
+	}
+	if len(labels) > 0 {
+		b.WriteByte('}')
+	}
+	fmt.Fprintf(m.w, "%s %g\n", b.String(), value)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
@@ -0,0 +1,194 @@
+package godog
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"runtime/debug"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/shirou/gopsutil/v4/process"
+)
+
+// RemedyReason 自愈措施的尝试记录追加到 reasons 中时使用的类型
+const RemedyReason ThresholdType = "Remedy"
+
+const (
+	// DefaultMemoryLimitRatio LowerMemoryLimit 默认将 GOMEMLIMIT 设置为 RSS 上限的比例
+	DefaultMemoryLimitRatio = 0.9
+	// DefaultRemedyGCPercent TuneGCPercent 默认的 GOGC
+	DefaultRemedyGCPercent = 50
+)
+
+// Remedy 内存自愈措施, Apply 的参数为 RSS 上限, 返回措施的说明
+type Remedy struct {
+	Name  string
+	Apply func(threshold uint64) string
+}
+
+// FreeOSMemory 强制 GC 并尽可能将内存归还操作系统
+func FreeOSMemory() Remedy {
+	return Remedy{Name: "FreeOSMemory", Apply: func(uint64) string {
+		debug.FreeOSMemory()
+		return "debug.FreeOSMemory()"
+	}}
+}
+
+// LowerMemoryLimit 将 GOMEMLIMIT 降低到 RSS 上限的 ratio 倍, 使 GC 更积极地回收
+func LowerMemoryLimit(ratio float64) Remedy {
+	return Remedy{Name: "LowerMemoryLimit", Apply: func(threshold uint64) string {
+		limit := int64(float64(threshold) * ratio)
+		prev := debug.SetMemoryLimit(-1)
+		if prev < limit {
+			limit = prev
+		}
+		debug.SetMemoryLimit(limit)
+		return fmt.Sprintf("GOMEMLIMIT %s -> %s", humanize.IBytes(uint64(prev)), humanize.IBytes(uint64(limit)))
+	}}
+}
+
+// TuneGCPercent 调整 GOGC, 只调低不调高, 并立即触发一次 GC
+func TuneGCPercent(percent int) Remedy {
+	return Remedy{Name: "TuneGCPercent", Apply: func(uint64) string {
+		prev := debug.SetGCPercent(percent)
+		if prev >= 0 && prev < percent {
+			debug.SetGCPercent(prev)
+			return fmt.Sprintf("GOGC %d, not raised to %d", prev, percent)
+		}
+		debug.FreeOSMemory()
+		return fmt.Sprintf("GOGC %d -> %d", prev, percent)
+	}}
+}
+
+// RemedyAttempt 一次自愈措施的尝试记录
+type RemedyAttempt struct {
+	Remedy    string `json:"remedy"`
+	Action    string `json:"action"`
+	Before    uint64 `json:"before"`
+	After     uint64 `json:"after"`
+	Recovered bool   `json:"recovered"`
+	Elapsed   string `json:"elapsed"`
+}
+
+// RemedyAction RSS 超标时依次尝试自愈措施, 每次措施后在 Grace 时间内重新检查 RSS,
+// 回落到上限及以下时停止, 否则所有措施之后执行 Next. 只处理自身进程的 RSS 超标, 其它情况直接执行 Next,
+// 汇总进程树(Config.ProcessTree)时检查自身及所有子孙进程的 RSS 之和
+type RemedyAction struct {
+	Remedies []Remedy
+	// Grace 每次措施后等待 RSS 回落的时间
+	Grace time.Duration
+	// Next 所有措施之后 RSS 仍然超标时执行的动作, 尝试记录追加到 reasons 中
+	Next Action
+}
+
+func (a *RemedyAction) DoAction(dir string, debug bool, reasons []ReasonItem) {
+	next, err := a.Step(dir, debug, reasons)
+	if errors.Is(err, ErrHandled) {
+		return
+	}
+	if a.Next != nil {
+		a.Next.DoAction(dir, debug, next)
+	}
+}
+
+// Step 依次尝试自愈措施, RSS 回落时返回 ErrHandled, 作为 Chain 中的一步
+func (a *RemedyAction) Step(dir string, debug bool, reasons []ReasonItem) ([]ReasonItem, error) {
+	threshold, tree, ok := remedyThreshold(reasons)
+	if !ok {
+		return reasons, nil
+	}
+
+	p, err := process.NewProcess(int32(os.Getpid()))
+	if err != nil {
+		return reasons, fmt.Errorf("get process: %w", err)
+	}
+
+	read := readRSS
+	if tree {
+		read = treeRSS
+	}
+	for _, remedy := range a.Remedies {
+		attempt := a.try(p, read, remedy, threshold)
+		log.Printf("godog remedy %s: %s, RSS %s -> %s, recovered: %t", attempt.Remedy, attempt.Action,
+			humanize.IBytes(attempt.Before), humanize.IBytes(attempt.After), attempt.Recovered)
+
+		reasons = appendRecord(reasons, ReasonItem{
+			Type:      RemedyReason,
+			Reason:    fmt.Sprintf("尝试 %s", remedy.Name),
+			Values:    []uint64{attempt.Before, attempt.After},
+			Threshold: threshold,
+			Detail:    attempt,
+		})
+		if attempt.Recovered {
+			return reasons, ErrHandled
+		}
+	}
+
+	return reasons, nil
+}
+
+// try 执行措施, 在 Grace 时间内每秒通过 read 检查一次 RSS
+func (a *RemedyAction) try(p *process.Process, read func(*process.Process) uint64, remedy Remedy, threshold uint64) RemedyAttempt {
+	attempt := RemedyAttempt{Remedy: remedy.Name, Before: read(p)}
+	start := time.Now()
+	attempt.Action = remedy.Apply(threshold)
+
+	for {
+		attempt.After = read(p)
+		if attempt.Recovered = attempt.After > 0 && attempt.After <= threshold; attempt.Recovered {
+			break
+		}
+
+		remaining := a.Grace - time.Since(start)
+		if remaining <= 0 {
+			break
+		}
+		time.Sleep(min(remaining, time.Second))
+	}
+
+	attempt.Elapsed = time.Since(start).String()
+	return attempt
+}
+
+func readRSS(p *process.Process) uint64 {
+	if memInfo, err := p.MemoryInfo(); err == nil {
+		return memInfo.RSS
+	}
+	return 0
+}
+
+// remedyThreshold 只有全部是自身进程的 RSS 超标时才尝试自愈, 返回 RSS 上限, tree 表示 RSS 为进程树之和
+func remedyThreshold(reasons []ReasonItem) (threshold uint64, tree, ok bool) {
+	for _, r := range reasons {
+		switch r.Type {
+		case RSS:
+			threshold, ok = r.Threshold.(uint64)
+			if !ok {
+				return 0, false, false
+			}
+			_, tree = r.Detail.(*ProcessTreeDetail)
+		case RemedyReason:
+		default:
+			return 0, false, false
+		}
+	}
+	return threshold, tree, ok
+}
+
+func remedyFactory(remedy func() Remedy) ActionFactory {
+	return func(d *Dog) (Action, error) {
+		if d.Pid != os.Getpid() {
+			return nil, fmt.Errorf("remedy %s only works for the current process", remedy().Name)
+		}
+		interval, _ := d.intervals()
+		return &RemedyAction{Remedies: []Remedy{remedy()}, Grace: interval}, nil
+	}
+}
+
+func init() {
+	RegisterAction("free-os-memory", remedyFactory(FreeOSMemory))
+	RegisterAction("memory-limit", remedyFactory(func() Remedy { return LowerMemoryLimit(DefaultMemoryLimitRatio) }))
+	RegisterAction("gc-percent", remedyFactory(func() Remedy { return TuneGCPercent(DefaultRemedyGCPercent) }))
+}
@@ -0,0 +1,176 @@
+package godog
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/dustin/go-humanize"
+)
+
+// Reload 使用新的配置重新创建阈值状态, 未完成的性能分析会被关闭, 超标进度和冷却状态会被清空,
+// 配置无效(见 Config.Validate)或修改 Pid 时返回错误, 不做修改
+func (w *Dog) Reload(options ...ConfigFn) error {
+	c, err := createConfig(options)
+	if err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+
+	w.mu.Lock()
+	if c.PidResolver != nil { // 继续监控已经找到的进程, 按该进程解析上限
+		c.Pid = w.Pid
+		c.resolveLimits()
+	}
+	if c.Pid != w.Pid {
+		w.mu.Unlock()
+		return fmt.Errorf("pid %d cannot be changed to %d by reload", w.Pid, c.Pid)
+	}
+	for _, state := range w.states {
+		state.closeProfile(w.Debug)
+	}
+	w.setConfig(c)
+	w.mu.Unlock()
+
+	select {
+	case w.reset <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// config 返回当前配置, Reload 替换配置而不修改原有配置, 返回后可以在锁外读取除 Interval, Jitter 和 Times 之外的字段
+func (w *Dog) config() *Config {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.Config
+}
+
+// SetInterval 运行时修改检查间隔和间隔补充随机时间, 立即按新的间隔等待下次检查
+func (w *Dog) SetInterval(interval, jitter time.Duration) error {
+	if interval <= 0 || jitter < 0 || jitter > interval {
+		return fmt.Errorf("invalid interval %s and jitter %s", interval, jitter)
+	}
+
+	w.mu.Lock()
+	w.Interval, w.Jitter = interval, jitter
+	w.mu.Unlock()
+
+	select {
+	case w.reset <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (w *Dog) intervals() (interval, jitter time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.Interval, w.Jitter
+}
+
+// SetTimes 运行时修改默认的触发次数, 判定策略单独设置了次数的阈值不受影响
+func (w *Dog) SetTimes(times int) error {
+	if times <= 0 {
+		return fmt.Errorf("invalid times %d", times)
+	}
+
+	w.mu.Lock()
+	w.Times = times
+	w.mu.Unlock()
+	return nil
+}
+
+// SetThresholds 运行时修改指定级别的阈值上限, 只能修改已经监控的指标, 任一指标未监控时不做修改,
+// 上限为 0 时只采样不判定
+func (w *Dog) SetThresholds(severity Severity, thresholds map[ThresholdType]uint64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	states, err := w.findStates(severity, thresholds)
+	if err != nil {
+		return err
+	}
+
+	for typ, threshold := range thresholds {
+		state := states[typ]
+		state.Threshold = threshold
+		state.breaching = false
+		for _, f := range state.followers {
+			if f.trend != nil { // 趋势检测使用同样的上限
+				f.Threshold = threshold
+			}
+		}
+	}
+	return nil
+}
+
+// findStates 查找指定级别的阈值状态, 需要持有 mu 调用
+func (w *Dog) findStates(severity Severity, thresholds map[ThresholdType]uint64) (map[ThresholdType]*thresholdState, error) {
+	states := make(map[ThresholdType]*thresholdState, len(thresholds))
+	for typ := range thresholds {
+		for _, state := range w.states {
+			if state.Type == typ && state.Severity == severity && state.trend == nil {
+				states[typ] = state
+				break
+			}
+		}
+		if states[typ] == nil {
+			return nil, fmt.Errorf("%s threshold %s is not watched", severity, typ)
+		}
+	}
+	return states, nil
+}
+
+// Pause 暂停监控, 清空超标进度并关闭未完成的性能分析, 使用 Resume 恢复
+func (w *Dog) Pause() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.paused.Swap(true) {
+		return
+	}
+	for _, state := range w.states {
+		state.Values = nil
+		state.samples = nil
+		state.breaching = false
+		if state.trend != nil {
+			state.trend.reset()
+		}
+		state.closeProfile(w.Debug)
+	}
+}
+
+// Resume 恢复 Pause 暂停的监控
+func (w *Dog) Resume() {
+	w.paused.Store(false)
+}
+
+// Paused 是否已暂停监控
+func (w *Dog) Paused() bool {
+	return w.paused.Load()
+}
+
+// ParseThreshold 解析阈值上限, 内存类型支持容量(例如 256MiB), 时间类型支持时长(例如 10ms, 单位为纳秒),
+// 其它类型为整数
+func ParseThreshold(typ ThresholdType, s string) (uint64, error) {
+	if v, err := strconv.ParseUint(s, 10, 64); err == nil {
+		return v, nil
+	}
+
+	switch typ {
+	case RSS, CgroupMemory, HeapLive, Stacks, HeapFree, RSSTrend, HeapLiveTrend:
+		v, err := humanize.ParseBytes(s)
+		if err != nil {
+			return 0, fmt.Errorf("parse %s threshold %q: %w", typ, s, err)
+		}
+		return v, nil
+	case GCPause, ThrottledTime:
+		d, err := time.ParseDuration(s)
+		if err != nil || d < 0 {
+			return 0, fmt.Errorf("parse %s threshold %q: invalid duration", typ, s)
+		}
+		return uint64(d), nil
+	default:
+		return 0, fmt.Errorf("parse %s threshold %q: invalid number", typ, s)
+	}
+}
@@ -0,0 +1,110 @@
+package godog
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"log"
+	"os"
+	"reflect"
+	"runtime"
+	"time"
+)
+
+// DefaultShutdownTimeout 优雅退出时等待关闭钩子的默认时间
+const DefaultShutdownTimeout = 10 * time.Second
+
+// ShutdownHook 优雅退出时执行的关闭钩子, ctx 在超时后取消
+type ShutdownHook func(ctx context.Context) error
+
+// HookResult 关闭钩子的执行结果
+type HookResult struct {
+	Name    string `json:"name"`
+	Error   string `json:"error,omitempty"`
+	Elapsed string `json:"elapsed"`
+}
+
+// OnShutdown 注册优雅退出时执行的关闭钩子
+func (w *Dog) OnShutdown(hook ShutdownHook) {
+	w.hooksLock.Lock()
+	defer w.hooksLock.Unlock()
+
+	w.hooks = append(w.hooks, hook)
+}
+
+// Context 返回 Dog 的上下文, 在优雅退出开始时取消, 可用于通知业务停止接收新请求
+func (w *Dog) Context() context.Context { return w.ctx }
+
+// GracefulExitAction 优雅退出动作: 取消 Context(), 在 ShutdownTimeout (未设置时为 DefaultShutdownTimeout) 内并发执行关闭钩子,
+// 将钩子执行结果写入 Dog.exit 后, 以 ExitCode 退出, 被监控的是其它进程时与 DefaultAction 相同
+func (w *Dog) GracefulExitAction() Action {
+	return ActionFn(func(dir string, debug bool, reasons []ReasonItem) {
+		if targetPid(reasons) != os.Getpid() {
+			DefaultAction(dir, debug, reasons)
+			return
+		}
+
+		log.Printf("program graceful exit by godog, reason: %v", reasons)
+
+		c := w.config()
+		w.cancel()
+		hooks := w.runShutdownHooks(cmp.Or(c.ShutdownTimeout, DefaultShutdownTimeout))
+		code := c.exitCode()
+		if err := WriteExitFile(dir, ExitFile{
+			Pid:     os.Getpid(),
+			Time:    time.Now().Format(time.RFC3339),
+			Reasons: reasons,
+			Hooks:   hooks,
+			Code:    &code,
+		}); err != nil {
+			log.Printf("E! write exit file error: %v", err)
+		}
+		os.Exit(code)
+	})
+}
+
+// runShutdownHooks 并发执行关闭钩子, 超时未完成的钩子记录为超时
+func (w *Dog) runShutdownHooks(timeout time.Duration) []HookResult {
+	w.hooksLock.Lock()
+	hooks := append([]ShutdownHook(nil), w.hooks...)
+	w.hooksLock.Unlock()
+
+	ctx, cancel := context.WithTimeoutCause(context.Background(), timeout, errShutdownTimeout)
+	defer cancel()
+
+	results := make([]HookResult, len(hooks))
+	done := make([]chan struct{}, len(hooks))
+	for i, hook := range hooks {
+		results[i].Name = runtime.FuncForPC(reflect.ValueOf(hook).Pointer()).Name()
+		done[i] = make(chan struct{})
+
+		go func(i int, hook ShutdownHook) {
+			defer close(done[i])
+			start := time.Now()
+			err := hook(ctx)
+			results[i].Elapsed = time.Since(start).String()
+			if err != nil {
+				results[i].Error = err.Error()
+			}
+		}(i, hook)
+	}
+
+	// 超时的钩子仍在运行, 只读取启动前写入的 Name
+	out := make([]HookResult, len(hooks))
+	for i := range hooks {
+		select {
+		case <-done[i]:
+			out[i] = results[i]
+		case <-ctx.Done():
+			out[i] = HookResult{
+				Name:    results[i].Name,
+				Error:   context.Cause(ctx).Error(),
+				Elapsed: timeout.String(),
+			}
+		}
+	}
+
+	return out
+}
+
+var errShutdownTimeout = errors.New("shutdown timeout")
@@ -0,0 +1,225 @@
+package godog
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/process"
+)
+
+const (
+	// DefaultSignalWait 发送信号后等待目标进程退出的默认时间
+	DefaultSignalWait = 10 * time.Second
+	// signalPollInterval 等待目标进程退出时的检查间隔
+	signalPollInterval = 100 * time.Millisecond
+)
+
+// SignalReason 发送信号的结果追加到 reasons 中时使用的类型
+const SignalReason ThresholdType = "Signal"
+
+// SignalStep 升级序列中的一步, 发送 Signal 后最多等待 Wait, 目标进程仍未退出时执行下一步
+type SignalStep struct {
+	Signal syscall.Signal
+	Wait   time.Duration
+}
+
+// DefaultSignalSteps 默认的升级序列: SIGTERM, 等待 10 秒, 然后 SIGKILL
+var DefaultSignalSteps = []SignalStep{
+	{Signal: syscall.SIGTERM, Wait: DefaultSignalWait},
+	{Signal: syscall.SIGKILL, Wait: DefaultSignalWait},
+}
+
+// SignalAction 按升级序列向被监控的进程发送信号, 直到目标进程退出,
+// 将生效的信号和目标进程的退出耗时写入 Dog.exit, 用于监控其它进程(例如 godog watch)
+type SignalAction struct {
+	// Steps 升级序列, 为空时使用 DefaultSignalSteps
+	Steps []SignalStep
+	// Group 为 true 时向目标进程所在的进程组发送信号, 只等待目标进程本身退出
+	Group bool
+	// Child 为 true 时只向超标原因中占用最多的子孙进程(见 Config.ProcessTree)发送信号, 没有时发送给目标进程
+	Child bool
+}
+
+// SignalResult 发送信号的结果
+type SignalResult struct {
+	Pid   int  `json:"pid"`
+	Group bool `json:"group,omitempty"`
+	// Sent 依次发送的信号
+	Sent []string `json:"sent,omitempty"`
+	// Signal 最终生效的信号, 目标进程没有退出时为空
+	Signal string `json:"signal,omitempty"`
+	// Elapsed 从发送第一个信号到目标进程退出(或放弃)的时长
+	Elapsed string `json:"elapsed"`
+	Error   string `json:"error,omitempty"`
+}
+
+func (a *SignalAction) DoAction(dir string, debug bool, reasons []ReasonItem) {
+	if _, err := a.Step(dir, debug, reasons); err != nil {
+		log.Printf("E! %v", err)
+	}
+}
+
+// Step 向目标进程发送信号并写入 Dog.exit, 发送结果追加到 reasons 中, 作为 Chain 中的一步.
+// 目标为当前进程时, 先写入 Dog.exit 再发送信号
+func (a *SignalAction) Step(dir string, debug bool, reasons []ReasonItem) ([]ReasonItem, error) {
+	pid := a.target(reasons)
+	exitFile := ExitFile{Pid: pid, Time: time.Now().Format(time.RFC3339), Reasons: reasons}
+	if pid == os.Getpid() {
+		log.Printf("program signaled by godog, reason: %v", reasons)
+		if err := WriteExitFile(dir, exitFile); err != nil {
+			log.Printf("E! %v", err)
+		}
+	}
+
+	result := a.Kill(pid)
+	log.Printf("godog signal pid %d, sent: %v, signal: %s, elapsed: %s, error: %q",
+		pid, result.Sent, result.Signal, result.Elapsed, result.Error)
+
+	exitFile.Signal = &result
+	if err := WriteExitFile(dir, exitFile); err != nil {
+		log.Printf("E! %v", err)
+	}
+
+	reason := fmt.Sprintf("信号 %s 生效, 耗时 %s", result.Signal, result.Elapsed)
+	if result.Signal == "" {
+		reason = fmt.Sprintf("信号 %v 未生效: %s", result.Sent, result.Error)
+	}
+	reasons = appendRecord(reasons, ReasonItem{Type: SignalReason, Reason: reason, Pid: pid})
+	if result.Signal == "" {
+		return reasons, fmt.Errorf("signal pid %d: %s", pid, result.Error)
+	}
+	return reasons, nil
+}
+
+// target 发送信号的进程
+func (a *SignalAction) target(reasons []ReasonItem) int {
+	if pid := offenderPid(reasons); a.Child && pid > 0 {
+		return pid
+	}
+	return targetPid(reasons)
+}
+
+// Kill 按升级序列向 pid 发送信号, 等待其退出
+func (a *SignalAction) Kill(pid int) (result SignalResult) {
+	result = SignalResult{Pid: pid, Group: a.Group}
+	start := time.Now()
+	defer func() { result.Elapsed = time.Since(start).String() }()
+
+	if !processAlive(pid) {
+		result.Error = fmt.Sprintf("process %d not found", pid)
+		return result
+	}
+
+	steps := a.Steps
+	if len(steps) == 0 {
+		steps = DefaultSignalSteps
+	}
+	for _, step := range steps {
+		if err := sendSignal(pid, step.Signal, a.Group); err != nil {
+			if !processAlive(pid) { // 发送前刚好退出
+				break
+			}
+			result.Error = fmt.Sprintf("send %s: %v", SignalName(step.Signal), err)
+			return result
+		}
+		result.Sent = append(result.Sent, SignalName(step.Signal))
+		if waitProcessExit(pid, step.Wait) {
+			result.Signal = SignalName(step.Signal)
+			return result
+		}
+	}
+
+	if len(result.Sent) > 0 && !processAlive(pid) {
+		result.Signal = result.Sent[len(result.Sent)-1]
+	} else {
+		result.Error = "process is still alive"
+	}
+	return result
+}
+
+// processAlive 进程是否存在, 僵尸进程视为已经退出
+func processAlive(pid int) bool {
+	p, err := process.NewProcess(int32(pid))
+	if err != nil {
+		return false
+	}
+	status, err := p.Status()
+	return err != nil || !slices.Contains(status, process.Zombie)
+}
+
+// waitProcessExit 在 timeout 内等待进程退出, 返回是否已经退出
+func waitProcessExit(pid int, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for processAlive(pid) {
+		if !time.Now().Before(deadline) {
+			return false
+		}
+		time.Sleep(signalPollInterval)
+	}
+	return true
+}
+
+// signalNames 可以按名称指定的信号
+var signalNames = map[string]syscall.Signal{
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"QUIT": syscall.SIGQUIT,
+	"KILL": syscall.SIGKILL,
+	"TERM": syscall.SIGTERM,
+}
+
+// SignalName 信号的名称, 例如 SIGTERM
+func SignalName(sig syscall.Signal) string {
+	for name, s := range signalNames {
+		if s == sig {
+			return "SIG" + name
+		}
+	}
+	return "SIG" + strconv.Itoa(int(sig))
+}
+
+// ParseSignal 解析信号名称(TERM 或 SIGTERM)或编号(15)
+func ParseSignal(s string) (syscall.Signal, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if sig, ok := signalNames[strings.TrimPrefix(s, "SIG")]; ok {
+		return sig, nil
+	}
+	if n, err := strconv.Atoi(strings.TrimPrefix(s, "SIG")); err == nil && n > 0 {
+		return syscall.Signal(n), nil
+	}
+	return 0, fmt.Errorf("unknown signal %q", s)
+}
+
+// ParseSignalSteps 解析升级序列, 例如 TERM:10s,KILL:5s, 省略等待时间时为 DefaultSignalWait
+func ParseSignalSteps(s string) ([]SignalStep, error) {
+	var steps []SignalStep
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+
+		name, wait, found := strings.Cut(item, ":")
+		sig, err := ParseSignal(name)
+		if err != nil {
+			return nil, err
+		}
+		step := SignalStep{Signal: sig, Wait: DefaultSignalWait}
+		if found {
+			if step.Wait, err = time.ParseDuration(strings.TrimSpace(wait)); err != nil || step.Wait < 0 {
+				return nil, fmt.Errorf("invalid signal wait %q", item)
+			}
+		}
+		steps = append(steps, step)
+	}
+	if len(steps) == 0 {
+		return nil, errors.New("no signal in " + strconv.Quote(s))
+	}
+	return steps, nil
+}
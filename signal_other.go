@@ -0,0 +1,24 @@
+//go:build !unix
+// +build !unix
+
+package godog
+
+import (
+	"errors"
+	"syscall"
+
+	"github.com/shirou/gopsutil/v4/process"
+)
+
+// sendSignal 向 pid 发送信号, 不支持进程组
+func sendSignal(pid int, sig syscall.Signal, group bool) error {
+	if group {
+		return errors.New("process group is not supported")
+	}
+
+	p, err := process.NewProcess(int32(pid))
+	if err != nil {
+		return err
+	}
+	return p.SendSignal(sig)
+}
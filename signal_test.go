@@ -0,0 +1,87 @@
+//go:build unix
+// +build unix
+
+package godog
+
+import (
+	"os/exec"
+	"slices"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// startChild 启动子进程并在测试结束时回收, 等待 shell 设置好信号处理
+func startChild(t *testing.T, script string) int {
+	t.Helper()
+	cmd := exec.Command("sh", "-c", script)
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	exited := make(chan struct{})
+	go func() {
+		_ = cmd.Wait()
+		close(exited)
+	}()
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		<-exited
+	})
+	time.Sleep(200 * time.Millisecond)
+	return cmd.Process.Pid
+}
+
+func TestSignalActionKill(t *testing.T) {
+	a := &SignalAction{Steps: []SignalStep{
+		{Signal: syscall.SIGTERM, Wait: 500 * time.Millisecond},
+		{Signal: syscall.SIGKILL, Wait: 5 * time.Second},
+	}}
+
+	tests := []struct {
+		name       string
+		script     string
+		wantSent   []string
+		wantSignal string
+	}{
+		{"exits on TERM", "exec sleep 100", []string{"SIGTERM"}, "SIGTERM"},
+		{"ignores TERM", `trap "" TERM; exec sleep 100`, []string{"SIGTERM", "SIGKILL"}, "SIGKILL"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pid := startChild(t, tt.script)
+			r := a.Kill(pid)
+			if r.Error != "" || r.Pid != pid || r.Signal != tt.wantSignal || !slices.Equal(r.Sent, tt.wantSent) {
+				t.Fatalf("Kill() = %+v, want sent %v, signal %s", r, tt.wantSent, tt.wantSignal)
+			}
+			if processAlive(pid) {
+				t.Fatalf("process %d is still alive", pid)
+			}
+		})
+	}
+}
+
+func TestSignalActionKillNotFound(t *testing.T) {
+	pid := startChild(t, "exit 0")
+	if r := (&SignalAction{}).Kill(pid); r.Error == "" || len(r.Sent) > 0 {
+		t.Fatalf("Kill() = %+v, want not found error", r)
+	}
+}
+
+func TestParseSignalSteps(t *testing.T) {
+	steps, err := ParseSignalSteps("INT:2s, TERM ,KILL:1s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []SignalStep{
+		{Signal: syscall.SIGINT, Wait: 2 * time.Second},
+		{Signal: syscall.SIGTERM, Wait: DefaultSignalWait},
+		{Signal: syscall.SIGKILL, Wait: time.Second},
+	}
+	if !slices.Equal(steps, want) {
+		t.Fatalf("ParseSignalSteps() = %v, want %v", steps, want)
+	}
+	if _, err := ParseSignalSteps("USR9"); err == nil {
+		t.Fatal("ParseSignalSteps(USR9) want error")
+	}
+}
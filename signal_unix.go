@@ -0,0 +1,25 @@
+//go:build unix
+// +build unix
+
+package godog
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// sendSignal 向 pid 或其所在的进程组发送信号, 不向 godog 自身所在的进程组发送
+func sendSignal(pid int, sig syscall.Signal, group bool) error {
+	if !group {
+		return syscall.Kill(pid, sig)
+	}
+
+	pgid, err := syscall.Getpgid(pid)
+	if err != nil {
+		return fmt.Errorf("get pgid of %d: %w", pid, err)
+	}
+	if pgid == syscall.Getpgrp() {
+		return fmt.Errorf("process group %d includes godog itself", pgid)
+	}
+	return syscall.Kill(-pgid, sig)
+}
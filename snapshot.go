@@ -0,0 +1,125 @@
+package godog
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// DefaultSubscribeBuffer 订阅通道的缓冲大小, 订阅者处理不及时, 缓冲满时丢弃事件
+const DefaultSubscribeBuffer = 16
+
+// State 最近一次采样的状态
+type State struct {
+	// Time 采样时间, 零值表示还没有采样
+	Time time.Time `json:"time"`
+	// RSS 常驻内存, 未监控 RSS 时为 0
+	RSS uint64 `json:"rss"`
+	// VMS 虚拟内存, 未监控 RSS 时为 0
+	VMS uint64 `json:"vms"`
+	// CPUPercent CPU 百分比, 未监控 CPU 时为 0
+	CPUPercent float64 `json:"cpuPercent"`
+	// Metrics 各个监控指标的采样值和超标进度
+	Metrics []MetricState `json:"metrics"`
+}
+
+// MetricState 单个监控指标的采样值和超标进度
+type MetricState struct {
+	Type      ThresholdType `json:"type"`
+	Severity  Severity      `json:"severity"`
+	Value     uint64        `json:"value"`
+	Threshold uint64        `json:"threshold"`
+	// Streak 当前超标次数, 达到 Times 时触发
+	Streak int `json:"streak"`
+	Times  int `json:"times"`
+	// Window 大于 0 时, Streak 为最近 Window 次采样中的超标次数
+	Window int `json:"window,omitempty"`
+	Detail any `json:"detail,omitempty"`
+}
+
+// EventType 订阅事件类型
+type EventType string
+
+const (
+	// EventSample 每次采样后发送, State 为采样结果
+	EventSample EventType = "sample"
+	// EventReached 超标触发时(执行动作前)发送, Reasons 为触发原因
+	EventReached EventType = "reached"
+)
+
+// Event 订阅事件
+type Event struct {
+	Type    EventType    `json:"type"`
+	State   State        `json:"state"`
+	Reasons []ReasonItem `json:"reasons,omitempty"`
+}
+
+// Snapshot 返回最近一次采样的状态, 还没有采样时 Time 为零值
+func (w *Dog) Snapshot() State {
+	if s := w.snapshot.Load(); s != nil {
+		return *s
+	}
+	return State{}
+}
+
+// Subscribe 订阅采样和超标事件, 调用 cancel 取消订阅并关闭通道,
+// 通道缓冲满时丢弃事件, 不会阻塞监控
+func (w *Dog) Subscribe() (events <-chan Event, cancel func()) {
+	ch := make(chan Event, DefaultSubscribeBuffer)
+
+	w.subsLock.Lock()
+	if w.subs == nil {
+		w.subs = map[chan Event]struct{}{}
+	}
+	w.subs[ch] = struct{}{}
+	w.subsLock.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			w.subsLock.Lock()
+			delete(w.subs, ch)
+			w.subsLock.Unlock()
+			close(ch)
+		})
+	}
+}
+
+func (w *Dog) publish(e Event) {
+	w.subsLock.Lock()
+	defer w.subsLock.Unlock()
+
+	for ch := range w.subs {
+		select {
+		case ch <- e:
+		default:
+			if w.Debug {
+				log.Printf("E! subscriber is slow, %s event dropped", e.Type)
+			}
+		}
+	}
+}
+
+// takeSnapshot 保存本次采样的状态, 并通知订阅者, 需要在判定触发(会清空超标进度)之前调用
+func (w *Dog) takeSnapshot() State {
+	s := w.sample
+	for _, state := range w.states {
+		if !state.sampled {
+			continue
+		}
+		s.Metrics = append(s.Metrics, MetricState{
+			Type:      state.Type,
+			Severity:  state.Severity,
+			Value:     state.last,
+			Threshold: state.Threshold,
+			Streak:    len(state.Values),
+			Times:     w.timesOf(state),
+			Window:    state.Window,
+			Detail:    state.Detail,
+		})
+	}
+
+	w.snapshot.Store(&s)
+	w.publish(Event{Type: EventSample, State: s})
+	return s
+}
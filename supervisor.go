@@ -0,0 +1,369 @@
+package godog
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// DefaultRestartBackoff 首次重启前的等待时间, 之后每次翻倍
+	DefaultRestartBackoff = time.Second
+	// DefaultMaxRestartBackoff 重启前的最大等待时间, 子进程运行超过该时间后等待时间恢复为初始值
+	DefaultMaxRestartBackoff = time.Minute
+	// DefaultRestartWindow 判定崩溃循环的默认时间窗口
+	DefaultRestartWindow = 5 * time.Minute
+)
+
+// DogRestarts 子进程退出和重启的记录文件, 与 Dog.exit 在同一目录, 每行一条 RestartRecord 的 JSON
+const DogRestarts = "Dog.restarts"
+
+// ErrCrashLoop 子进程在时间窗口内重启次数过多, 不再重启
+var ErrCrashLoop = errors.New("crash loop")
+
+// RestartPolicy 子进程退出后的重启策略
+type RestartPolicy string
+
+const (
+	// RestartAlways 子进程退出后总是重启
+	RestartAlways RestartPolicy = "always"
+	// RestartOnFailure 子进程异常退出(退出码非 0, 被信号终止或超标)后重启
+	RestartOnFailure RestartPolicy = "on-failure"
+	// RestartNever 不重启
+	RestartNever RestartPolicy = "never"
+)
+
+// 子进程退出的原因
+const (
+	ExitCauseExit    = "exit"
+	ExitCauseFailure = "failure"
+	ExitCauseAction  = "action"
+)
+
+// forwardSignals 转发给子进程的信号, 除 SIGHUP 外转发后不再重启子进程
+var forwardSignals = []os.Signal{os.Interrupt, syscall.SIGTERM, syscall.SIGQUIT, syscall.SIGHUP}
+
+// Supervisor 启动并监控子进程, 转发信号, 超标或退出后按重启策略重启
+type Supervisor struct {
+	// Command 子进程的命令和参数
+	Command []string
+	// Dog 监控子进程的看门狗, 需要使用 WithPidResolver(s.ChildPid) 创建并由调用方启动,
+	// 所有子进程共用, 指标和管理接口在重启后仍然可用; 为 nil 时 Run 按 Options 和 Action 创建并启动
+	Dog *Dog
+	// Options Dog 为 nil 时创建看门狗的配置, 进程号自动设置为子进程
+	Options []ConfigFn
+	// Action Dog 为 nil 时超标执行的动作, 例如通知, 为 nil 时只记录日志; 动作执行的同时停止子进程
+	Action ActionFactory
+	// Stop 超标或停止时终止子进程的方式, 为 nil 时使用默认的 SignalAction
+	Stop *SignalAction
+
+	// Restart 重启策略, 默认 RestartOnFailure
+	Restart RestartPolicy
+	// Backoff 首次重启前的等待时间, 之后每次翻倍, 默认 DefaultRestartBackoff
+	Backoff time.Duration
+	// MaxBackoff 重启前的最大等待时间, 默认 DefaultMaxRestartBackoff
+	MaxBackoff time.Duration
+	// MaxRestarts RestartWindow 内最多重启的次数, 超过时视为崩溃循环不再重启, 0 表示不限制
+	MaxRestarts int
+	// RestartWindow 判定崩溃循环的时间窗口, 默认 DefaultRestartWindow
+	RestartWindow time.Duration
+
+	mu sync.Mutex
+	// child 正在运行的子进程, 没有时为 nil
+	child *childExit
+}
+
+// RestartRecord 子进程退出的记录
+type RestartRecord struct {
+	Time string `json:"time"`
+	Pid  int    `json:"pid"`
+	// Cause 退出原因: exit, failure 或 action
+	Cause    string `json:"cause"`
+	ExitCode int    `json:"exitCode"`
+	// Signal 子进程被信号终止时的信号
+	Signal string `json:"signal,omitempty"`
+	Uptime string `json:"uptime"`
+	// Reasons 超标导致退出时的原因
+	Reasons []ReasonItem `json:"reasons,omitempty"`
+	// Restarts 此前已经重启的次数
+	Restarts int `json:"restarts"`
+	// Next 之后的处理: restart, stop 或 crash-loop
+	Next string `json:"next"`
+	// Backoff 重启前的等待时间
+	Backoff string `json:"backoff,omitempty"`
+}
+
+// childExit 子进程一次运行的结果
+type childExit struct {
+	pid     int
+	state   *os.ProcessState
+	uptime  time.Duration
+	reasons []ReasonItem
+	// stopped 收到了停止信号或 ctx 已取消
+	stopped bool
+}
+
+// ChildPid 正在运行的子进程的进程号, 没有时返回 ErrTargetNotFound, 用作 Dog 的 PidResolver
+func (s *Supervisor) ChildPid() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.child == nil {
+		return 0, fmt.Errorf("supervised child: %w", ErrTargetNotFound)
+	}
+	return s.child.pid, nil
+}
+
+// Run 启动子进程直到不再重启, 返回子进程最后的退出码, 崩溃循环时返回 ErrCrashLoop.
+// ctx 取消或收到 SIGINT, SIGTERM, SIGQUIT 时, 转发给子进程并在其退出后返回
+func (s *Supervisor) Run(ctx context.Context) (int, error) {
+	if len(s.Command) == 0 {
+		return 1, errors.New("command is empty")
+	}
+	policy := s.Restart
+	if policy == "" {
+		policy = RestartOnFailure
+	}
+	if !slices.Contains([]RestartPolicy{RestartAlways, RestartOnFailure, RestartNever}, policy) {
+		return 1, fmt.Errorf("unknown restart policy %q", policy)
+	}
+
+	dog := s.Dog
+	if dog == nil {
+		var err error
+		if dog, err = s.newDog(); err != nil {
+			return 1, err
+		}
+		defer dog.Stop(context.Background())
+	}
+	events, unsubscribe := dog.Subscribe()
+	defer unsubscribe()
+	go s.stopOnReached(events)
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, forwardSignals...)
+	defer signal.Stop(sigs)
+
+	baseBackoff := cmp.Or(s.Backoff, DefaultRestartBackoff)
+	maxBackoff := max(cmp.Or(s.MaxBackoff, DefaultMaxRestartBackoff), baseBackoff)
+	window := cmp.Or(s.RestartWindow, DefaultRestartWindow)
+	backoff := baseBackoff
+	var restarts []time.Time
+
+	for {
+		e, err := s.runChild(ctx, sigs)
+		if err != nil {
+			return 1, err
+		}
+
+		code, sig := exitStatus(e.state)
+		rec := RestartRecord{
+			Time:     time.Now().Format(time.RFC3339),
+			Pid:      e.pid,
+			Cause:    ExitCauseFailure,
+			ExitCode: code,
+			Signal:   sig,
+			Uptime:   e.uptime.String(),
+			Reasons:  e.reasons,
+			Restarts: len(restarts),
+			Next:     "stop",
+		}
+		if len(e.reasons) > 0 {
+			rec.Cause = ExitCauseAction
+		} else if code == 0 {
+			rec.Cause = ExitCauseExit
+		}
+
+		now := time.Now()
+		restarts = slices.DeleteFunc(restarts, func(t time.Time) bool { return now.Sub(t) > window })
+		if e.uptime >= maxBackoff { // 运行足够久视为恢复正常
+			backoff = baseBackoff
+		}
+		switch {
+		case e.stopped, policy == RestartNever, policy == RestartOnFailure && rec.Cause == ExitCauseExit:
+		case s.MaxRestarts > 0 && len(restarts) >= s.MaxRestarts:
+			rec.Next = "crash-loop"
+		default:
+			rec.Next, rec.Backoff = "restart", backoff.String()
+		}
+
+		log.Printf("godog child %d exited, cause: %s, exit code: %d, signal: %q, uptime: %s, next: %s %s",
+			rec.Pid, rec.Cause, rec.ExitCode, rec.Signal, rec.Uptime, rec.Next, rec.Backoff)
+		if err := appendRestartRecord(dog.config().Dir, rec); err != nil {
+			log.Printf("E! %v", err)
+		}
+
+		switch rec.Next {
+		case "crash-loop":
+			return code, fmt.Errorf("%w: %d restarts in %s", ErrCrashLoop, len(restarts), window)
+		case "stop":
+			return code, nil
+		}
+
+		if !waitRestart(ctx, sigs, backoff) {
+			return code, nil
+		}
+		restarts = append(restarts, time.Now())
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// newDog 按 Options 和 Action 创建并启动监控子进程的看门狗
+func (s *Supervisor) newDog() (*Dog, error) {
+	dog := New(append(slices.Clip(s.Options), WithPidResolver(s.ChildPid))...)
+	dog.Action = ActionFn(LogAction)
+	if s.Action != nil {
+		action, err := s.Action(dog)
+		if err != nil {
+			return nil, fmt.Errorf("create child action: %w", err)
+		}
+		dog.Action = action
+	}
+	if err := dog.Start(); err != nil {
+		return nil, err
+	}
+	return dog, nil
+}
+
+// stopOnReached 子进程超标时停止子进程, 设置了 SignalAction.Child 时只停止占用最多的子孙进程
+func (s *Supervisor) stopOnReached(events <-chan Event) {
+	for ev := range events {
+		if ev.Type != EventReached {
+			continue
+		}
+		reasons := slices.DeleteFunc(slices.Clone(ev.Reasons), func(r ReasonItem) bool { return r.Severity == Warn })
+		if len(reasons) == 0 {
+			continue
+		}
+
+		pid := s.stopper().target(reasons)
+		s.mu.Lock()
+		e := s.child
+		if e == nil || e.reasons != nil || targetPid(reasons) != e.pid { // 已经退出或正在停止
+			s.mu.Unlock()
+			continue
+		}
+		if pid == e.pid {
+			e.reasons = reasons
+		}
+		s.mu.Unlock()
+
+		if pid != e.pid { // 只终止占用最多的子孙进程
+			log.Printf("godog stop descendant %d of child %d, reason: %v", pid, e.pid, reasons)
+			s.stopper().Kill(pid)
+			continue
+		}
+		log.Printf("godog stop child %d, reason: %v", e.pid, reasons)
+		s.stopper().Kill(e.pid)
+	}
+}
+
+// runChild 启动子进程, 由 Dog 通过 ChildPid 监控, 直到子进程退出
+func (s *Supervisor) runChild(ctx context.Context, sigs <-chan os.Signal) (*childExit, error) {
+	cmd := exec.Command(s.Command[0], s.Command[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start %s: %w", strings.Join(s.Command, " "), err)
+	}
+	start := time.Now()
+	exited := make(chan struct{})
+	go func() {
+		_ = cmd.Wait()
+		close(exited)
+	}()
+
+	e := &childExit{pid: cmd.Process.Pid}
+	s.mu.Lock()
+	s.child = e
+	s.mu.Unlock()
+	log.Printf("godog child %d started: %s", e.pid, strings.Join(s.Command, " "))
+
+	done := ctx.Done()
+	for waiting := true; waiting; {
+		select {
+		case <-exited:
+			waiting = false
+		case sig := <-sigs:
+			if err := cmd.Process.Signal(sig); err != nil {
+				log.Printf("E! godog forward %v to child %d error: %v", sig, e.pid, err)
+			}
+			e.stopped = e.stopped || sig != syscall.SIGHUP
+		case <-done:
+			done, e.stopped = nil, true
+			go s.stopper().Kill(e.pid)
+		}
+	}
+
+	// 超标的原因在停止子进程之前记录
+	s.mu.Lock()
+	s.child = nil
+	s.mu.Unlock()
+	e.state, e.uptime = cmd.ProcessState, time.Since(start)
+	return e, nil
+}
+
+func (s *Supervisor) stopper() *SignalAction {
+	if s.Stop != nil {
+		return s.Stop
+	}
+	return &SignalAction{}
+}
+
+// waitRestart 等待 backoff 后重启, 期间 ctx 取消或收到停止信号时返回 false
+func waitRestart(ctx context.Context, sigs <-chan os.Signal, backoff time.Duration) bool {
+	timer := time.NewTimer(backoff)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			return true
+		case <-ctx.Done():
+			return false
+		case sig := <-sigs:
+			if sig != syscall.SIGHUP {
+				return false
+			}
+		}
+	}
+}
+
+// exitStatus 子进程的退出码, 被信号终止时为 128 + 信号值
+func exitStatus(state *os.ProcessState) (code int, sig string) {
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return 128 + int(ws.Signal()), SignalName(ws.Signal())
+	}
+	return state.ExitCode(), ""
+}
+
+// appendRestartRecord 将记录追加到 dir 目录下的 Dog.restarts 文件
+func appendRestartRecord(dir string, rec RestartRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("marshal restart record: %w", err)
+	}
+
+	name := filepath.Join(dir, DogRestarts)
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("open restart history %s: %w", name, err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("write restart history %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package godog
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/shirou/gopsutil/v4/process"
+)
+
+// ErrTargetNotFound 没有找到要监控的进程
+var ErrTargetNotFound = errors.New("target process not found")
+
+// PidResolver 查找要监控的进程号, 设置后目标进程退出(例如重启)时重新查找
+type PidResolver func() (int, error)
+
+// PidFile 从 pid 文件中读取进程号
+func PidFile(path string) PidResolver {
+	return func() (int, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return 0, fmt.Errorf("read pidfile %s: %w", path, err)
+		}
+		pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+		if err != nil || pid <= 0 {
+			return 0, fmt.Errorf("invalid pidfile %s content %q", path, data)
+		}
+		return pid, nil
+	}
+}
+
+// ProcessName 按进程名查找进程号, 有多个同名进程时选择最早启动的(通常是主进程)
+func ProcessName(name string) PidResolver {
+	return func() (int, error) {
+		ps, err := process.Processes()
+		if err != nil {
+			return 0, fmt.Errorf("list processes: %w", err)
+		}
+
+		self := int32(os.Getpid())
+		var pid int32
+		var created int64
+		for _, p := range ps {
+			if p.Pid == self {
+				continue
+			}
+			if n, err := p.Name(); err != nil || n != name {
+				continue
+			}
+			c, err := p.CreateTime()
+			if err != nil {
+				continue
+			}
+			if pid == 0 || c < created {
+				pid, created = p.Pid, c
+			}
+		}
+		if pid == 0 {
+			return 0, fmt.Errorf("process %s: %w", name, ErrTargetNotFound)
+		}
+		return int(pid), nil
+	}
+}
+
+// target 返回要监控的进程, 设置了 PidResolver 时, 目标进程不存在则重新查找,
+// 找到新的进程后按新的进程号重新创建阈值状态, 没有找到时返回 nil
+func (w *Dog) target(p *process.Process) *process.Process {
+	c := w.config()
+	if c.PidResolver == nil {
+		return p
+	}
+	if p != nil {
+		if running, err := p.IsRunning(); err == nil && running {
+			return p
+		}
+	}
+
+	pid, err := c.PidResolver()
+	if err == nil && p != nil && int(p.Pid) == pid {
+		err = fmt.Errorf("pid %d: %w", pid, ErrTargetNotFound) // pid 文件还没有更新
+	}
+	var np *process.Process
+	if err == nil {
+		np, err = process.NewProcess(int32(pid))
+	}
+	if err != nil {
+		if c.Debug || p != nil {
+			log.Printf("E! godog resolve target error: %v", err)
+		}
+		if p != nil {
+			w.retarget(0)
+		}
+		return nil
+	}
+
+	log.Printf("godog watching pid %d", pid)
+	w.retarget(pid)
+	return np
+}
+
+// retarget 按新的进程号重新创建阈值状态并解析上限, pid 为 0 时表示目标进程已经退出
+func (w *Dog) retarget(pid int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, state := range w.states {
+		state.closeProfile(w.Debug)
+	}
+	c := *w.Config
+	c.Pid = pid
+	c.resolveLimits()
+	w.setConfig(&c)
+}
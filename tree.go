@@ -0,0 +1,138 @@
+package godog
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/shirou/gopsutil/v4/process"
+)
+
+// DefaultTreeTopN 超标原因中列出的占用最多的子孙进程个数
+const DefaultTreeTopN = 3
+
+// TreeProcess 子孙进程的资源占用
+type TreeProcess struct {
+	Pid        int     `json:"pid"`
+	Name       string  `json:"name"`
+	RSS        uint64  `json:"rss"`
+	CPUPercent float64 `json:"cpuPercent"`
+}
+
+// ProcessTreeDetail 汇总进程树(Config.ProcessTree)时 RSS 或 CPU 的附加信息
+type ProcessTreeDetail struct {
+	Type ThresholdType `json:"type"`
+	// Self 目标进程本身的占用, RSS 为字节数, CPU 为百分比
+	Self uint64 `json:"self"`
+	// Descendants 子孙进程个数
+	Descendants int `json:"descendants"`
+	// Top 按该指标占用最多的子孙进程
+	Top []TreeProcess `json:"top,omitempty"`
+}
+
+// String 列出占用最多的子孙进程, 例如 ffmpeg(123) 512 MiB, python(456) 128 MiB
+func (d *ProcessTreeDetail) String() string {
+	items := make([]string, 0, len(d.Top))
+	for _, p := range d.Top {
+		if d.Type == CPU {
+			items = append(items, fmt.Sprintf("%s(%d) %.1f%%", p.Name, p.Pid, p.CPUPercent))
+		} else {
+			items = append(items, fmt.Sprintf("%s(%d) %s", p.Name, p.Pid, humanize.IBytes(p.RSS)))
+		}
+	}
+	return strings.Join(items, ", ")
+}
+
+// processTree 最近一次采样时遍历的子孙进程, 只在监控协程中访问
+type processTree struct {
+	time  time.Time
+	procs []TreeProcess
+	// logged 已经记录过查找子孙进程的错误(例如没有 pgrep), 之后只在调试时记录
+	logged bool
+}
+
+// descendants 通过 Children() 遍历目标进程的所有子孙进程, 同一次采样中 RSS 和 CPU 共用遍历结果
+func (w *Dog) descendants(p *process.Process) []TreeProcess {
+	if w.tree.time.Equal(w.sample.Time) {
+		return w.tree.procs
+	}
+
+	w.tree.time, w.tree.procs = w.sample.Time, nil
+	err := walkDescendants(p, func(c *process.Process) {
+		tp := TreeProcess{Pid: int(c.Pid)}
+		tp.Name, _ = c.Name()
+		tp.RSS = readRSS(c)
+		tp.CPUPercent, _ = c.CPUPercent()
+		w.tree.procs = append(w.tree.procs, tp)
+	})
+	if err != nil && (!w.tree.logged || w.Debug) {
+		w.tree.logged = true
+		log.Printf("E! godog get descendants of %d error, only counting found processes: %v", p.Pid, err)
+	}
+	return w.tree.procs
+}
+
+// walkDescendants 通过 Children() 广度优先遍历 p 的所有子孙进程, 返回第一个错误(没有子进程不算错误)
+func walkDescendants(p *process.Process, fn func(c *process.Process)) (err error) {
+	visited := map[int32]bool{p.Pid: true}
+	for queue := []*process.Process{p}; len(queue) > 0; queue = queue[1:] {
+		children, e := queue[0].Children()
+		if e != nil {
+			if err == nil && !errors.Is(e, process.ErrorNoChildren) {
+				err = fmt.Errorf("children of %d: %w", queue[0].Pid, e)
+			}
+			continue
+		}
+
+		for _, c := range children {
+			if !visited[c.Pid] {
+				visited[c.Pid] = true
+				queue = append(queue, c)
+				fn(c)
+			}
+		}
+	}
+	return err
+}
+
+// treeRSS 进程及其所有子孙进程的 RSS 之和
+func treeRSS(p *process.Process) uint64 {
+	rss := readRSS(p)
+	_ = walkDescendants(p, func(c *process.Process) { rss += readRSS(c) })
+	return rss
+}
+
+// treeDetail 按 typ 排序子孙进程, 返回附加信息和子孙进程的占用之和
+func treeDetail(typ ThresholdType, self uint64, procs []TreeProcess) (*ProcessTreeDetail, float64) {
+	value := func(p TreeProcess) float64 {
+		if typ == CPU {
+			return p.CPUPercent
+		}
+		return float64(p.RSS)
+	}
+
+	var sum float64
+	for _, p := range procs {
+		sum += value(p)
+	}
+	top := slices.Clone(procs)
+	slices.SortFunc(top, func(a, b TreeProcess) int { return cmp.Compare(value(b), value(a)) })
+	d := &ProcessTreeDetail{Type: typ, Self: self, Descendants: len(procs)}
+	d.Top = top[:min(len(top), DefaultTreeTopN)]
+	return d, sum
+}
+
+// offenderPid 超标原因中占用最多的子孙进程, 没有汇总进程树时返回 0
+func offenderPid(reasons []ReasonItem) int {
+	for _, r := range reasons {
+		if d, ok := r.Detail.(*ProcessTreeDetail); ok && len(d.Top) > 0 {
+			return d.Top[0].Pid
+		}
+	}
+	return 0
+}
@@ -0,0 +1,116 @@
+package godog
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dustin/go-humanize"
+)
+
+// DefaultTrendWindow 趋势检测默认的采样窗口大小
+const DefaultTrendWindow = 10
+
+// TrendDetail 趋势检测触发时的附加信息
+type TrendDetail struct {
+	// Slope 线性回归得到的每秒增长量
+	Slope float64 `json:"slope"`
+	// Growth 每小时增长量
+	Growth string `json:"growth"`
+	// ETA 预计达到上限的剩余时间
+	ETA string `json:"eta"`
+	// Horizon 配置的预警时间
+	Horizon string `json:"horizon"`
+	// Samples 参与回归的采样数
+	Samples int `json:"samples"`
+
+	eta time.Duration
+}
+
+// trendWindow 保存最近的采样, 用线性回归预测达到上限的时间
+type trendWindow struct {
+	size    int
+	horizon time.Duration
+	times   []time.Time
+	values  []uint64
+}
+
+func newTrendWindow(size int, horizon time.Duration) *trendWindow {
+	return &trendWindow{size: size, horizon: horizon}
+}
+
+func (w *trendWindow) add(now time.Time, value uint64) {
+	w.times = append(w.times, now)
+	w.values = append(w.values, value)
+	if n := len(w.values); n > w.size {
+		w.times = w.times[n-w.size:]
+		w.values = w.values[n-w.size:]
+	}
+}
+
+func (w *trendWindow) reset() {
+	w.times = nil
+	w.values = nil
+}
+
+// project 对窗口内的采样做线性回归, 窗口未满或没有增长时 ok 为 false
+func (w *trendWindow) project(threshold uint64) (d TrendDetail, ok bool) {
+	n := len(w.values)
+	if n < w.size || n < 2 {
+		return d, false
+	}
+
+	var sumX, sumY, sumXY, sumXX float64
+	for i, v := range w.values {
+		x := w.times[i].Sub(w.times[0]).Seconds()
+		y := float64(v)
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+	denominator := float64(n)*sumXX - sumX*sumX
+	if denominator == 0 {
+		return d, false
+	}
+
+	d.Slope = (float64(n)*sumXY - sumX*sumY) / denominator
+	if d.Slope <= 0 {
+		return d, false
+	}
+
+	last := w.values[n-1]
+	if last < threshold {
+		d.eta = time.Duration(float64(threshold-last) / d.Slope * float64(time.Second))
+	}
+	d.Growth = humanize.IBytes(uint64(d.Slope*3600)) + "/h"
+	d.ETA = d.eta.Round(time.Second).String()
+	d.Horizon = w.horizon.String()
+	d.Samples = n
+	return d, true
+}
+
+// reached 加入采样, 预计在 horizon 内达到上限时返回 true
+func (w *trendWindow) reached(value, threshold uint64, detail *any) bool {
+	w.add(time.Now(), value)
+	d, ok := w.project(threshold)
+	if !ok || d.eta >= w.horizon {
+		return false
+	}
+
+	*detail = d
+	return true
+}
+
+func (w *trendWindow) reason() string {
+	return fmt.Sprintf("%d 次采样的增长趋势预计在 %s 内超标", w.size, w.horizon)
+}
+
+// newTrendState 创建跟随 source 采样的趋势检测状态
+func newTrendState(typ ThresholdType, source *thresholdState, window int, horizon time.Duration) *thresholdState {
+	t := newThresholdState(typ, source.Threshold, nil, source.Dir, source.Pid)
+	t.trend = newTrendWindow(window, horizon)
+	// 趋势本身已经是多次采样的结果, 一次满足即触发
+	t.Times = 1
+	source.followers = append(source.followers, t)
+	return t
+}
@@ -3,16 +3,20 @@ package godog
 import (
 	"context"
 	"crypto/rand"
-	"log"
+	"fmt"
 	"math/big"
-	"os"
 	"strconv"
+	"strings"
 	"time"
-
-	"github.com/dustin/go-humanize"
 )
 
 func Tick(ctx context.Context, interval, jitter time.Duration, f func() error) error {
+	return tick(ctx, func() (time.Duration, time.Duration) { return interval, jitter }, nil, f)
+}
+
+// tick 与 Tick 相同, 每次等待前通过 intervals 重新读取间隔, 收到 reset 时按新的间隔重新等待
+func tick(ctx context.Context, intervals func() (interval, jitter time.Duration), reset <-chan struct{}, f func() error) error {
+	interval, jitter := intervals()
 	timer := time.NewTimer(interval)
 	defer timer.Stop()
 
@@ -29,10 +33,22 @@ func Tick(ctx context.Context, interval, jitter time.Duration, f func() error) e
 			return err
 		}
 
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		case <-timer.C:
+		for waiting := true; waiting; {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-reset:
+				if !timer.Stop() {
+					select {
+					case <-timer.C:
+					default:
+					}
+				}
+			case <-timer.C:
+				waiting = false
+			}
+
+			interval, jitter = intervals()
 			timer.Reset(interval)
 		}
 	}
@@ -40,43 +56,52 @@ func Tick(ctx context.Context, interval, jitter time.Duration, f func() error) e
 	return ctx.Err()
 }
 
+// GetEnvSize 读取容量形式的环境变量, 取值有误时退出程序, 不希望退出时使用 EnvReader
 func GetEnvSize(name string, defaultValue uint64) uint64 {
-	env := os.Getenv(name)
-	if env == "" {
-		return defaultValue
+	var r EnvReader
+	defer r.fatal()
+	return r.Size(name, defaultValue)
+}
+
+// parsePercent 解析百分比, 例如 90%, 不以 % 结尾时 ok 为 false
+func parsePercent(s string) (percent float64, ok bool, err error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasSuffix(s, "%") {
+		return 0, false, nil
 	}
 
-	val, err := humanize.ParseBytes(env)
-	if err != nil {
-		log.Fatalf("parse env %s error: %v", name, err)
+	val, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+	if err != nil || val <= 0 {
+		return 0, true, fmt.Errorf("invalid percent %q", s)
 	}
-	return val
+	return val, true, nil
 }
 
+// GetEnvInt 读取整数形式的环境变量, 取值有误时退出程序, 不希望退出时使用 EnvReader
 func GetEnvInt(name string, defaultValue uint64) uint64 {
-	env := os.Getenv(name)
-	if env == "" {
-		return defaultValue
-	}
+	var r EnvReader
+	defer r.fatal()
+	return r.Int(name, defaultValue)
+}
 
-	val, err := strconv.ParseUint(env, 10, 64)
-	if err != nil {
-		log.Fatalf("parse env %s error: %v", name, err)
+// ParsePolicy 解析判定策略 M/N(最近 N 次采样中 M 次超标) 或 M(连续 M 次超标)
+func ParsePolicy(s string) (times, window int, err error) {
+	m, n, found := strings.Cut(s, "/")
+	times, err = strconv.Atoi(strings.TrimSpace(m))
+	if err == nil && found {
+		window, err = strconv.Atoi(strings.TrimSpace(n))
+	}
+	if err != nil || times <= 0 || window < 0 {
+		return 0, 0, fmt.Errorf("invalid policy %q, expect M/N", s)
 	}
-	return val
+	return times, window, nil
 }
 
+// GetEnvDuration 读取时长形式的环境变量, 取值有误时退出程序, 不希望退出时使用 EnvReader
 func GetEnvDuration(name string, defaultValue time.Duration) time.Duration {
-	env := os.Getenv(name)
-	if env == "" {
-		return defaultValue
-	}
-
-	val, err := time.ParseDuration(env)
-	if err != nil {
-		log.Fatalf("parse env %s error: %v", name, err)
-	}
-	return val
+	var r EnvReader
+	defer r.fatal()
+	return r.Duration(name, defaultValue)
 }
 
 // RandomSleep will sleep for a random amount of time up to max.
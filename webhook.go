@@ -0,0 +1,174 @@
+package godog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	// DefaultWebhookTimeout Webhook 单次请求的默认超时时间
+	DefaultWebhookTimeout = 5 * time.Second
+	// DefaultWebhookRetryInterval Webhook 首次重试的间隔, 之后每次翻倍
+	DefaultWebhookRetryInterval = time.Second
+)
+
+// WebhookAction 将超标原因 POST 到 URL, 然后执行 Next
+type WebhookAction struct {
+	// URL 通知地址
+	URL string
+	// Template text/template 格式的请求体, 数据为 WebhookData, 为空时发送 WebhookData 的 JSON.
+	// 可以使用 json 函数输出 JSON 值, 例如钉钉:
+	//   {"msgtype":"text","text":{"content":{{json (printf "%s %s: %v" .Host .Program .Reasons)}}}}
+	Template string
+	// Headers 请求头, 默认 Content-Type: application/json
+	Headers map[string]string
+	// Timeout 单次请求超时时间, 默认 DefaultWebhookTimeout
+	Timeout time.Duration
+	// Retries 失败后的最大重试次数
+	Retries int
+	// RetryInterval 首次重试的间隔, 之后每次翻倍, 默认 DefaultWebhookRetryInterval
+	RetryInterval time.Duration
+	// Next 通知之后执行的动作, 通常为终止动作, 为 nil 时只通知
+	Next Action
+	// Client 发送请求的客户端, 默认 http.DefaultClient
+	Client *http.Client
+}
+
+// WebhookData Webhook 模板数据
+type WebhookData struct {
+	ExitFile
+	// Host 主机名
+	Host string `json:"host"`
+	// Program 程序名
+	Program string `json:"program"`
+}
+
+func (a *WebhookAction) DoAction(dir string, debug bool, reasons []ReasonItem) {
+	if _, err := a.Step(dir, debug, reasons); err != nil {
+		log.Printf("E! %v", err)
+	}
+
+	if a.Next != nil {
+		a.Next.DoAction(dir, debug, reasons)
+	}
+}
+
+// Step 发送通知, 作为 Chain 中的一步
+func (a *WebhookAction) Step(dir string, debug bool, reasons []ReasonItem) ([]ReasonItem, error) {
+	if err := a.Notify(reasons); err != nil {
+		return reasons, fmt.Errorf("webhook %s: %w", a.URL, err)
+	}
+	if debug {
+		log.Printf("webhook %s notified", a.URL)
+	}
+	return reasons, nil
+}
+
+// Notify 发送通知, 失败时按 Retries 重试
+func (a *WebhookAction) Notify(reasons []ReasonItem) error {
+	body, err := a.render(reasons)
+	if err != nil {
+		return err
+	}
+
+	interval := a.RetryInterval
+	if interval <= 0 {
+		interval = DefaultWebhookRetryInterval
+	}
+	for i := 0; ; i++ {
+		if err = a.post(body); err == nil || i >= a.Retries {
+			return err
+		}
+		time.Sleep(interval)
+		interval *= 2
+	}
+}
+
+var webhookFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+func (a *WebhookAction) render(reasons []ReasonItem) ([]byte, error) {
+	host, _ := os.Hostname()
+	data := WebhookData{
+		ExitFile: ExitFile{
+			Pid:     targetPid(reasons),
+			Time:    time.Now().Format(time.RFC3339),
+			Reasons: reasons,
+		},
+		Host:    host,
+		Program: filepath.Base(os.Args[0]),
+	}
+	if a.Template == "" {
+		return json.Marshal(data)
+	}
+
+	t, err := template.New("webhook").Funcs(webhookFuncs).Parse(a.Template)
+	if err != nil {
+		return nil, fmt.Errorf("parse webhook template: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("execute webhook template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (a *WebhookAction) post(body []byte) error {
+	timeout := a.Timeout
+	if timeout <= 0 {
+		timeout = DefaultWebhookTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range a.Headers {
+		req.Header.Set(k, v)
+	}
+
+	client := a.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	rsp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("post: %w", err)
+	}
+	defer rsp.Body.Close()
+
+	rspBody, _ := io.ReadAll(io.LimitReader(rsp.Body, 1024))
+	if rsp.StatusCode < 200 || rsp.StatusCode >= 300 {
+		return fmt.Errorf("status %d: %s", rsp.StatusCode, strings.TrimSpace(string(rspBody)))
+	}
+	return nil
+}
+
+// ParseHeaders 解析 "Name: Value; Name2: Value2" 形式的请求头
+func ParseHeaders(s string) map[string]string {
+	headers := map[string]string{}
+	for _, h := range strings.Split(s, ";") {
+		if k, v, ok := strings.Cut(h, ":"); ok && strings.TrimSpace(k) != "" {
+			headers[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	return headers
+}
@@ -0,0 +1,125 @@
+package godog
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// webhookServer 前 fails 次请求返回 500, 之后返回 200, 记录最后一次请求
+func webhookServer(t *testing.T, fails int32) (srv *httptest.Server, calls *atomic.Int32, last func() (*http.Request, string)) {
+	t.Helper()
+	calls = &atomic.Int32{}
+	var req atomic.Pointer[http.Request]
+	var body atomic.Value
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		req.Store(r)
+		body.Store(string(data))
+		if calls.Add(1) <= fails {
+			http.Error(w, "try again", http.StatusInternalServerError)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, calls, func() (*http.Request, string) {
+		s, _ := body.Load().(string)
+		return req.Load(), s
+	}
+}
+
+var webhookReasons = []ReasonItem{{Type: RSS, Severity: Critical, Reason: "连续 1 次超标", Values: []uint64{2048}, Threshold: uint64(1024), Pid: 1234}}
+
+func TestWebhookActionRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		fails     int32
+		retries   int
+		wantCalls int32
+		wantErr   bool
+	}{
+		{"success", 0, 0, 1, false},
+		{"retry then success", 2, 3, 3, false},
+		{"retries exhausted", 5, 2, 3, true},
+		{"no retry", 1, 0, 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, calls, _ := webhookServer(t, tt.fails)
+			a := &WebhookAction{URL: srv.URL, Retries: tt.retries, RetryInterval: time.Millisecond}
+			err := a.Notify(webhookReasons)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Notify() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if tt.wantErr && !strings.Contains(err.Error(), "status 500") {
+				t.Fatalf("Notify() error = %v, want status 500", err)
+			}
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Fatalf("calls = %d, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestWebhookActionBody(t *testing.T) {
+	srv, _, last := webhookServer(t, 0)
+	a := &WebhookAction{URL: srv.URL, Headers: map[string]string{"X-Token": "secret"}}
+	if err := a.Notify(webhookReasons); err != nil {
+		t.Fatal(err)
+	}
+
+	r, body := last()
+	if got := r.Header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+	if got := r.Header.Get("X-Token"); got != "secret" {
+		t.Errorf("X-Token = %q, want secret", got)
+	}
+	var data WebhookData
+	if err := json.Unmarshal([]byte(body), &data); err != nil {
+		t.Fatalf("unmarshal body %s: %v", body, err)
+	}
+	if data.Pid != 1234 || len(data.Reasons) != 1 || data.Reasons[0].Type != RSS || data.Program == "" {
+		t.Errorf("body = %s, want pid 1234 with the RSS reason", body)
+	}
+}
+
+func TestWebhookActionTemplate(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		want     string
+		wantErr  string
+	}{
+		{"text", `pid={{.Pid}} type={{(index .Reasons 0).Type}}`, "pid=1234 type=RSS", ""},
+		{"json func", `{"content":{{json (printf "%d %s" .Pid (index .Reasons 0).Reason)}}}`,
+			`{"content":"1234 连续 1 次超标"}`, ""},
+		{"parse error", `{{.Pid`, "", "parse webhook template"},
+		{"execute error", `{{.Missing}}`, "", "execute webhook template"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, calls, last := webhookServer(t, 0)
+			a := &WebhookAction{URL: srv.URL, Template: tt.template}
+			err := a.Notify(webhookReasons)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) || calls.Load() != 0 {
+					t.Fatalf("Notify() error = %v, calls = %d, want %q without request", err, calls.Load(), tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, body := last(); body != tt.want {
+				t.Fatalf("body = %s, want %s", body, tt.want)
+			}
+		})
+	}
+}